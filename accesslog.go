@@ -0,0 +1,180 @@
+package siesta
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat is the layout of the entries written by an AccessLogger.
+type AccessLogFormat int
+
+const (
+	// CommonLogFormat is the Apache Common Log Format.
+	CommonLogFormat AccessLogFormat = iota
+	// CombinedLogFormat is the Apache Combined Log Format, which adds
+	// the referer and user agent to the Common Log Format.
+	CombinedLogFormat
+	// JSONLogFormat writes every entry as a JSON object on its own line.
+	JSONLogFormat
+)
+
+// clfTimeFormat is the timestamp layout used by Apache.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogger logs every request served by a Service.
+//
+// Entries in the Common and Combined formats are followed by three extra
+// fields: the quoted route pattern, the quoted request ID and the latency
+// in microseconds. Empty values are logged as "-".
+type AccessLogger struct {
+	requestIDKey string
+
+	emit func(r *http.Request, e *accessLogEntry)
+}
+
+// accessLogEntry holds the details of a served request.
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	Host      string    `json:"host"`
+	User      string    `json:"user,omitempty"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Route     string    `json:"route,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+
+	latency time.Duration
+}
+
+// NewAccessLogger returns an AccessLogger that writes entries in the given
+// format to out. Writes are serialized, so out doesn't need to be safe for
+// concurrent use.
+func NewAccessLogger(out io.Writer, format AccessLogFormat) *AccessLogger {
+	var mu sync.Mutex
+	return &AccessLogger{
+		requestIDKey: RequestIDContextKey,
+		emit: func(r *http.Request, e *accessLogEntry) {
+			var buf bytes.Buffer
+			switch format {
+			case JSONLogFormat:
+				json.NewEncoder(&buf).Encode(e)
+			default:
+				e.writeCLF(&buf, format == CombinedLogFormat)
+			}
+
+			mu.Lock()
+			out.Write(buf.Bytes())
+			mu.Unlock()
+		},
+	}
+}
+
+// SetRequestIDKey sets the Context key the request ID is read from.
+// It defaults to RequestIDContextKey.
+func (l *AccessLogger) SetRequestIDKey(key string) {
+	l.requestIDKey = key
+}
+
+// Wrap returns an http.Handler that serves requests with s
+// and logs each of them once s is done.
+func (l *AccessLogger) Wrap(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := NewSiestaContext()
+		rw := newResponseWriter(w)
+
+		e := &accessLogEntry{
+			Time:      time.Now(),
+			Host:      r.RemoteAddr,
+			Method:    r.Method,
+			URI:       r.RequestURI,
+			Proto:     r.Proto,
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+		}
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			e.Host = host
+		}
+		if e.URI == "" {
+			e.URI = r.URL.RequestURI()
+		}
+		if r.URL.User != nil {
+			e.User = r.URL.User.Username()
+		}
+
+		defer func() {
+			p := recover()
+
+			e.latency = time.Since(e.Time)
+			e.LatencyMS = float64(e.latency) / float64(time.Millisecond)
+			e.Status = rw.Status()
+			e.Bytes = rw.Size()
+			if e.Status == 0 {
+				if p != nil {
+					e.Status = http.StatusInternalServerError
+				} else {
+					e.Status = http.StatusOK
+				}
+			}
+			e.Route, _ = c.Get(RouteContextKey).(string)
+			e.RequestID, _ = c.Get(l.requestIDKey).(string)
+			l.emit(r, e)
+
+			if p != nil {
+				panic(p)
+			}
+		}()
+
+		s.ServeHTTPInContext(c, rw, r)
+	})
+}
+
+// writeCLF writes e to buf in the Common or Combined Log Format,
+// followed by the extra fields described in AccessLogger.
+func (e *accessLogEntry) writeCLF(buf *bytes.Buffer, combined bool) {
+	buf.WriteString(dashIfEmpty(e.Host))
+	buf.WriteString(" - ")
+	buf.WriteString(dashIfEmpty(e.User))
+	buf.WriteString(" [")
+	buf.WriteString(e.Time.Format(clfTimeFormat))
+	buf.WriteString("] ")
+	buf.WriteString(strconv.Quote(e.Method + " " + e.URI + " " + e.Proto))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Itoa(e.Status))
+	buf.WriteByte(' ')
+	if e.Bytes > 0 {
+		buf.WriteString(strconv.FormatInt(e.Bytes, 10))
+	} else {
+		buf.WriteByte('-')
+	}
+	if combined {
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.Quote(dashIfEmpty(e.Referer)))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.Quote(dashIfEmpty(e.UserAgent)))
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Quote(dashIfEmpty(e.Route)))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Quote(dashIfEmpty(e.RequestID)))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(int64(e.latency/time.Microsecond), 10))
+	buf.WriteByte('\n')
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
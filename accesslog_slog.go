@@ -0,0 +1,34 @@
+//go:build go1.21
+// +build go1.21
+
+package siesta
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// NewSlogAccessLogger returns an AccessLogger that emits every entry
+// as an info-level record through h.
+func NewSlogAccessLogger(h slog.Handler) *AccessLogger {
+	logger := slog.New(h)
+	return &AccessLogger{
+		requestIDKey: RequestIDContextKey,
+		emit: func(r *http.Request, e *accessLogEntry) {
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+				slog.String("host", e.Host),
+				slog.String("user", e.User),
+				slog.String("method", e.Method),
+				slog.String("uri", e.URI),
+				slog.String("proto", e.Proto),
+				slog.Int("status", e.Status),
+				slog.Int64("bytes", e.Bytes),
+				slog.String("referer", e.Referer),
+				slog.String("user_agent", e.UserAgent),
+				slog.String("route", e.Route),
+				slog.String("request_id", e.RequestID),
+				slog.Duration("latency", e.latency),
+			)
+		},
+	}
+}
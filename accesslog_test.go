@@ -0,0 +1,54 @@
+package siesta
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestAccessLoggerCommonLogFormat(t *testing.T) {
+	s := NewService("logs")
+	s.AddPre(NewRequestIdentifier().Handle)
+	s.Route(http.MethodGet, "/items/:id", "Gets an item", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+
+	var buf bytes.Buffer
+	h := NewAccessLogger(&buf, CombinedLogFormat).Wrap(s)
+
+	r := httptest.NewRequest(http.MethodGet, "/logs/items/7?x=1", nil)
+	r.Header.Set("User-Agent", "tester")
+	r.Header.Set("X-Request-ID", "abc123")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	re := regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "GET /logs/items/7\?x=1 HTTP/1\.1" 201 5 "-" "tester" "/logs/items/:id" "abc123" \d+\n$`)
+	if !re.MatchString(buf.String()) {
+		t.Errorf("unexpected log line %q", buf.String())
+	}
+}
+
+func TestAccessLoggerJSONFormat(t *testing.T) {
+	s := NewService("json-logs")
+
+	var buf bytes.Buffer
+	h := NewAccessLogger(&buf, JSONLogFormat).Wrap(s)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/json-logs/nowhere", nil))
+
+	var e map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := float64(http.StatusNotFound), e["status"]; want != got {
+		t.Errorf("expected status %v got %v", want, got)
+	}
+	if _, ok := e["route"]; ok {
+		t.Errorf("expected no route, got %v", e["route"])
+	}
+	if want, got := "/json-logs/nowhere", e["uri"]; want != got {
+		t.Errorf("expected uri %v got %v", want, got)
+	}
+}
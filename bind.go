@@ -0,0 +1,212 @@
+package siesta
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Bind defines the parameters of the struct dst points to, as
+// Params.Struct does, and parses them from r as Params.ParseRequest does.
+func Bind(r *http.Request, dst interface{}) error {
+	var params Params
+	if err := params.Struct(dst); err != nil {
+		return err
+	}
+	return params.ParseRequest(r)
+}
+
+// Struct defines a parameter for every exported field of the struct dst
+// points to, which gets the value of the parameter once parsed. Fields are
+// described by their tags:
+//
+//	type Query struct {
+//		ResourceID int      `param:"resourceID" usage:"Resource identifier" required:"true"`
+//		Limit      int      `param:"limit" usage:"Max results" default:"10"`
+//		Tags       []string `param:"tag"`
+//		Page       struct {
+//			Size int `param:"size"`
+//		} `param:"page"`
+//		Internal string `param:"-"`
+//		Session  string `param:"session" source:"cookie"`
+//	}
+//
+// The param tag sets the name of the parameter, which defaults to the name
+// of the field, and "-" skips the field. Fields of struct type define the
+// parameters of their own fields, prefixed by their name and a dot, as in
+// "page.size"; embedded structs have no prefix. Structs without exported
+// fields, such as time.Time, are not supported. Fields may have the types
+// of all the definers of Params, and slices of them, which are parsed as
+// the SliceXXX types. The source tag names the ParamSource of the
+// parameter: form, header, cookie or path. Fields keep their values unless
+// the parameter is given, or the default tag sets them. Slice fields have
+// no defaults, and given values are appended to them. Parameters can't be
+// defined twice, whether by two fields or by a field and a definer.
+func (rp *Params) Struct(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("siesta: Params.Struct requires a pointer to a struct")
+	}
+	if rp.fset == nil {
+		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
+	}
+	return rp.structFields(v.Elem(), "")
+}
+
+func (rp *Params) structFields(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("param")
+		if tag == "-" || (sf.PkgPath != "" && !sf.Anonymous) {
+			continue
+		}
+
+		field := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct && hasExportedFields(sf.Type) {
+			nested := prefix
+			if tag != "" {
+				nested += tag + "."
+			} else if !sf.Anonymous {
+				nested += sf.Name + "."
+			}
+			if err := rp.structFields(field, nested); err != nil {
+				return err
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = sf.Name
+		}
+		name = prefix + name
+
+		if rp.fset.Lookup(name) != nil {
+			return fmt.Errorf("siesta: field %s: duplicate param %q", sf.Name, name)
+		}
+		if err := rp.fieldVar(field, name, sf.Tag.Get("usage")); err != nil {
+			return fmt.Errorf("siesta: field %s: %v", sf.Name, err)
+		}
+		if def, ok := sf.Tag.Lookup("default"); ok && sf.Type.Kind() != reflect.Slice {
+			f := rp.fset.Lookup(name)
+			if err := f.Value.Set(def); err != nil {
+				return fmt.Errorf("siesta: field %s: bad default %q: %v", sf.Name, def, err)
+			}
+			f.DefValue = def
+		}
+		if required, _ := strconv.ParseBool(sf.Tag.Get("required")); required {
+			rp.Required(name)
+		}
+		if tag, ok := sf.Tag.Lookup("source"); ok {
+			source, err := parseParamSource(tag)
+			if err != nil {
+				return fmt.Errorf("siesta: field %s: %v", sf.Name, err)
+			}
+			rp.setSource(name, []ParamSource{source})
+		}
+	}
+	return nil
+}
+
+// hasExportedFields reports whether the struct type t has exported
+// fields, directly or through embedded structs.
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath == "" {
+			return true
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && hasExportedFields(sf.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldVar defines a parameter stored in field.
+func (rp *Params) fieldVar(field reflect.Value, name, usage string) error {
+	addr := field.Addr()
+	t := field.Type()
+
+	if t.Kind() == reflect.Slice {
+		var value flag.Value
+		switch elem := t.Elem(); {
+		case elem == durationType:
+			value = convertSlice(addr, (*SDuration)(nil))
+		case elem.Kind() == reflect.String:
+			value = convertSlice(addr, (*SString)(nil))
+		case elem.Kind() == reflect.Bool:
+			value = convertSlice(addr, (*SBool)(nil))
+		case elem.Kind() == reflect.Int:
+			value = convertSlice(addr, (*SInt)(nil))
+		case elem.Kind() == reflect.Int64:
+			value = convertSlice(addr, (*SInt64)(nil))
+		case elem.Kind() == reflect.Uint:
+			value = convertSlice(addr, (*SUint)(nil))
+		case elem.Kind() == reflect.Uint64:
+			value = convertSlice(addr, (*SUint64)(nil))
+		case elem.Kind() == reflect.Float64:
+			value = convertSlice(addr, (*SFloat64)(nil))
+		}
+		if value == nil {
+			return fmt.Errorf("unsupported type %s", t)
+		}
+		rp.fset.Var(value, name, usage)
+		return nil
+	}
+
+	// The current values of fields are their defaults.
+	convert := func(ptr interface{}) interface{} {
+		return addr.Convert(reflect.TypeOf(ptr)).Interface()
+	}
+	switch {
+	case t == durationType:
+		p := convert((*time.Duration)(nil)).(*time.Duration)
+		rp.fset.DurationVar(p, name, *p, usage)
+	case t.Kind() == reflect.String:
+		p := convert((*string)(nil)).(*string)
+		rp.fset.StringVar(p, name, *p, usage)
+	case t.Kind() == reflect.Bool:
+		p := convert((*bool)(nil)).(*bool)
+		rp.fset.BoolVar(p, name, *p, usage)
+	case t.Kind() == reflect.Int:
+		p := convert((*int)(nil)).(*int)
+		rp.fset.IntVar(p, name, *p, usage)
+	case t.Kind() == reflect.Int64:
+		p := convert((*int64)(nil)).(*int64)
+		rp.fset.Int64Var(p, name, *p, usage)
+	case t.Kind() == reflect.Uint:
+		p := convert((*uint)(nil)).(*uint)
+		rp.fset.UintVar(p, name, *p, usage)
+	case t.Kind() == reflect.Uint64:
+		p := convert((*uint64)(nil)).(*uint64)
+		rp.fset.Uint64Var(p, name, *p, usage)
+	case t.Kind() == reflect.Float64:
+		p := convert((*float64)(nil)).(*float64)
+		rp.fset.Float64Var(p, name, *p, usage)
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+	return nil
+}
+
+// convertSlice converts addr, a pointer to a slice, to the type of ptr,
+// a pointer to a SliceXXX type. It returns nil if it can't, as with
+// slices of named element types.
+func convertSlice(addr reflect.Value, ptr flag.Value) flag.Value {
+	target := reflect.TypeOf(ptr)
+	if !addr.Type().ConvertibleTo(target) {
+		return nil
+	}
+	return addr.Convert(target).Interface().(flag.Value)
+}
@@ -0,0 +1,129 @@
+package siesta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type pageParams struct {
+	Size   int `param:"size" default:"50"`
+	Number int `param:"number"`
+}
+
+type listParams struct {
+	pageParams `param:"-"`
+
+	ResourceID int           `param:"resourceID" usage:"Resource identifier" required:"true"`
+	Limit      uint          `param:"limit" usage:"Max results" default:"10"`
+	Timeout    time.Duration `param:"timeout"`
+	Verbose    bool          `param:"verbose"`
+	Name       string
+	Tags       []string   `param:"tag"`
+	IDs        SInt64     `param:"id"`
+	Ratios     []float64  `param:"ratio"`
+	Page       pageParams `param:"page"`
+	Ignored    string     `param:"-"`
+	unexported string
+}
+
+func TestBind(t *testing.T) {
+	dst := listParams{Name: "default"}
+	r := httptest.NewRequest(http.MethodGet, "/?resourceID=7&timeout=2s&verbose&tag=a,b&tag=c&id=1&id=2&ratio=0.5&page.number=3&Ignored=x", nil)
+	r.ParseForm()
+	if err := Bind(r, &dst); err != nil {
+		t.Fatal(err)
+	}
+
+	want := listParams{
+		ResourceID: 7,
+		Limit:      10,
+		Timeout:    2 * time.Second,
+		Verbose:    true,
+		Name:       "default",
+		Tags:       []string{"a", "b", "c"},
+		IDs:        SInt64{1, 2},
+		Ratios:     []float64{0.5},
+		Page:       pageParams{Size: 50, Number: 3},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("expected %+v, got %+v", want, dst)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?limit=5", nil)
+	r.ParseForm()
+	if _, ok := Bind(r, &listParams{}).(*MissingParamsError); !ok {
+		t.Error("expected a *MissingParamsError")
+	}
+}
+
+func TestParamsStructUsage(t *testing.T) {
+	var p Params
+	if err := p.Struct(&listParams{}); err != nil {
+		t.Fatal(err)
+	}
+	usage := p.Usage()
+	for name, want := range map[string][3]string{
+		"resourceID": {"resourceID", "int", "Resource identifier (required)"},
+		"limit":      {"limit", "uint", "Max results"},
+		"tag":        {"tag", "[]string", ""},
+		"id":         {"id", "[]int64", ""},
+		"page.size":  {"page.size", "int", ""},
+		"Name":       {"Name", "string", ""},
+	} {
+		if got := usage[name]; got != want {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+	if _, ok := usage["size"]; ok {
+		t.Error("expected the skipped embedded struct to define no params")
+	}
+
+	if err := p.Struct(&struct{ C chan int }{}); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+	if err := p.Struct(listParams{}); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+}
+
+func TestParamsStructDuplicates(t *testing.T) {
+	type Inner struct {
+		Limit int `param:"limit"`
+	}
+	for _, dst := range []interface{}{
+		&struct {
+			A int `param:"x"`
+			B int `param:"x"`
+		}{},
+		&struct {
+			Inner
+			Limit int `param:"limit"`
+		}{},
+	} {
+		var p Params
+		err := p.Struct(dst)
+		if err == nil || !strings.Contains(err.Error(), "duplicate param") {
+			t.Errorf("%T: expected a duplicate param error, got %v", dst, err)
+		}
+	}
+
+	var p Params
+	p.Int("limit", 0, "")
+	if err := p.Struct(&Inner{}); err == nil {
+		t.Error("expected an error for a param already defined")
+	}
+}
+
+func TestParamsStructUnsupported(t *testing.T) {
+	var p Params
+	err := p.Struct(&struct {
+		When time.Time `param:"when"`
+	}{})
+	if want := "siesta: field When: unsupported type time.Time"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,321 @@
+package siesta
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ErrUnsupportedMediaType is returned when binding a request
+// body with a Content-Type that can't be decoded.
+var ErrUnsupportedMediaType = errors.New("siesta: unsupported media type")
+
+// BodyBinder decodes request bodies into structs according to their
+// Content-Type:
+//
+//   - application/json and types ending in +json are decoded with
+//     encoding/json, rejecting fields that dst doesn't have.
+//   - application/xml, text/xml and types ending in +xml are decoded
+//     with encoding/xml, rejecting elements that dst has no field for.
+//     Unknown attributes are ignored.
+//   - URL-encoded and multipart forms are bound as by Params.Struct,
+//     rejecting unknown fields, from http.Request.PostForm. They can't
+//     be bound on routes with the ParseQuery form policy.
+//
+// Errors in the body are returned as ParamErrors, like those
+// of a Params that collects errors. Bodies over the size limit
+// fail with ErrRequestBodyTooLarge, and other media types with
+// ErrUnsupportedMediaType.
+type BodyBinder struct {
+	maxSize      int64
+	allowUnknown bool
+}
+
+// defaultBodyBinder is used by BindBody.
+var defaultBodyBinder = NewBodyBinder()
+
+// NewBodyBinder returns a BodyBinder that reads up to 1 MiB
+// of JSON and XML bodies.
+func NewBodyBinder() *BodyBinder {
+	return &BodyBinder{maxSize: 1 << 20}
+}
+
+// BindBody decodes the body of r into dst with a default BodyBinder.
+func BindBody(r *http.Request, dst interface{}) error {
+	return defaultBodyBinder.Bind(r, dst)
+}
+
+// SetMaxSize sets the maximum size of the JSON and XML bodies read.
+// Forms are limited by the Service as they are parsed.
+func (b *BodyBinder) SetMaxSize(n int64) {
+	b.maxSize = n
+}
+
+// AllowUnknownFields makes the binder ignore fields and XML
+// elements that dst doesn't have instead of rejecting them.
+func (b *BodyBinder) AllowUnknownFields() {
+	b.allowUnknown = true
+}
+
+// Bind decodes the body of r into dst, which must be a pointer.
+func (b *BodyBinder) Bind(r *http.Request, dst interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ErrUnsupportedMediaType
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return b.bindJSON(r, dst)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return b.bindXML(r, dst)
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		return b.bindForm(r, dst, mediaType == "multipart/form-data")
+	}
+	return ErrUnsupportedMediaType
+}
+
+func (b *BodyBinder) body(r *http.Request) io.Reader {
+	if b.maxSize <= 0 {
+		return r.Body
+	}
+	return &sizeLimitedReader{r: r.Body, remaining: b.maxSize}
+}
+
+func (b *BodyBinder) bindJSON(r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(b.body(r))
+	if !b.allowUnknown {
+		dec.DisallowUnknownFields()
+	}
+
+	err := dec.Decode(dst)
+	if err == nil {
+		if dec.Decode(&json.RawMessage{}) != io.EOF {
+			return bodyError("must contain a single JSON value")
+		}
+		return nil
+	}
+	if errors.Is(err, ErrRequestBodyTooLarge) {
+		return err
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		// typeErr.Value is the kind of the JSON value, not the value
+		// given, so Value is left empty.
+		return ParamErrors{{
+			Name:   typeErr.Field,
+			Type:   typeErr.Type.String(),
+			Reason: "must be a valid " + typeErr.Type.String(),
+		}}
+	}
+	if msg := err.Error(); strings.HasPrefix(msg, "json: unknown field ") {
+		name := strings.Trim(strings.TrimPrefix(msg, "json: unknown field "), `"`)
+		return ParamErrors{{Name: name, Reason: "is not a known parameter"}}
+	}
+	if err == io.EOF {
+		return bodyError("must not be empty")
+	}
+	return bodyError("must be valid JSON: " + err.Error())
+}
+
+func (b *BodyBinder) bindXML(r *http.Request, dst interface{}) error {
+	// The body is read first to look for unknown elements,
+	// which encoding/xml ignores.
+	data, err := ioutil.ReadAll(b.body(r))
+	if err != nil {
+		return err
+	}
+
+	err = xml.Unmarshal(data, dst)
+	switch {
+	case err == io.EOF:
+		return bodyError("must not be empty")
+	case err != nil:
+		return bodyError("must be valid XML: " + err.Error())
+	case b.allowUnknown:
+		return nil
+	}
+
+	if name := unknownXMLElement(data, reflect.TypeOf(dst)); name != "" {
+		return ParamErrors{{Name: name, Reason: "is not a known parameter"}}
+	}
+	return nil
+}
+
+var (
+	xmlUnmarshalerType  = reflect.TypeOf((*xml.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// unknownXMLElement returns the name of the first element of the XML
+// document data that encoding/xml ignores when decoding it into a value
+// of type t, or "" if there is none.
+func unknownXMLElement(data []byte, t reflect.Type) string {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			return unknownXMLChild(dec, t)
+		}
+	}
+}
+
+// unknownXMLChild looks for unknown elements in the content
+// of the element just read by dec, of type t.
+func unknownXMLChild(dec *xml.Decoder, t reflect.Type) string {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			child, ok := xmlChildType(t, tok.Name.Local)
+			if !ok {
+				return tok.Name.Local
+			}
+			if child == nil {
+				if dec.Skip() != nil {
+					return ""
+				}
+			} else if name := unknownXMLChild(dec, child); name != "" {
+				return name
+			}
+		case xml.EndElement:
+			return ""
+		}
+	}
+}
+
+// xmlChildType returns the type of the child element name of an element of
+// type t, as decoded by encoding/xml. It returns false if the element is
+// ignored, and a nil type if the contents of the element are not checked.
+func xmlChildType(t reflect.Type, name string) (reflect.Type, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || reflect.PtrTo(t).Implements(xmlUnmarshalerType) ||
+		reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return nil, true
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if (sf.PkgPath != "" && !sf.Anonymous) || sf.Name == "XMLName" {
+			continue
+		}
+		tag := sf.Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		fieldName := opts[0]
+		if i := strings.LastIndex(fieldName, " "); i >= 0 {
+			// Drop the namespace.
+			fieldName = fieldName[i+1:]
+		}
+
+		mode := ""
+		if len(opts) > 1 {
+			mode = opts[1]
+		}
+		switch mode {
+		case "attr", "chardata", "cdata", "comment":
+			continue
+		case "innerxml", "any":
+			return nil, true
+		}
+
+		if sf.Anonymous && fieldName == "" {
+			if child, ok := xmlChildType(sf.Type, name); ok {
+				return child, true
+			}
+			continue
+		}
+		if fieldName == "" {
+			fieldName = sf.Name
+		}
+		if i := strings.Index(fieldName, ">"); i >= 0 {
+			// Paths such as "a>b" are not checked past their first element.
+			if fieldName[:i] == name {
+				return nil, true
+			}
+			continue
+		}
+		if fieldName == name {
+			child := sf.Type
+			if child.Kind() == reflect.Slice && child.Elem().Kind() != reflect.Uint8 {
+				child = child.Elem()
+			}
+			return child, true
+		}
+	}
+	return nil, false
+}
+
+func (b *BodyBinder) bindForm(r *http.Request, dst interface{}, multipart bool) error {
+	switch {
+	case isQueryOnly(r):
+		return errors.New("siesta: form bodies can't be bound under the ParseQuery form policy")
+	case r.Form == nil:
+		if err := parseLazyForm(r); err != nil {
+			return err
+		}
+	case multipart && r.MultipartForm == nil:
+		// ParseForm leaves multipart bodies unread.
+		if err := parseForm(r, ParseMultipart); err != nil {
+			return err
+		}
+	case r.PostForm == nil:
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+	}
+
+	var params Params
+	params.SetStrict(!b.allowUnknown)
+	params.SetCollectErrors(true)
+	if err := params.Struct(dst); err != nil {
+		return err
+	}
+	return params.Parse(r.PostForm)
+}
+
+// bodyError returns the error for a body that can't be decoded at all.
+func bodyError(reason string) ParamErrors {
+	return ParamErrors{{Name: "body", Reason: reason}}
+}
+
+// sizeLimitedReader fails with ErrRequestBodyTooLarge
+// when reading more than remaining bytes.
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrRequestBodyTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrRequestBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,152 @@
+package siesta
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bindBodyItem struct {
+	Name  string   `json:"name" xml:"name" param:"name"`
+	Count int      `json:"count" xml:"count" param:"count"`
+	Tags  []string `json:"tags" xml:"tag" param:"tag"`
+}
+
+func newBodyRequest(contentType, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	return r
+}
+
+func TestBindBody(t *testing.T) {
+	want := bindBodyItem{Name: "widget", Count: 3, Tags: []string{"a", "b"}}
+	for contentType, body := range map[string]string{
+		"application/json; charset=utf-8":   `{"name":"widget","count":3,"tags":["a","b"]}`,
+		"application/vnd.api+json":          `{"name":"widget","count":3,"tags":["a","b"]}`,
+		"application/xml":                   `<item><name>widget</name><count>3</count><tag>a</tag><tag>b</tag></item>`,
+		"application/x-www-form-urlencoded": `name=widget&count=3&tag=a,b`,
+	} {
+		var dst bindBodyItem
+		if err := BindBody(newBodyRequest(contentType, body), &dst); err != nil {
+			t.Errorf("%s: %v", contentType, err)
+			continue
+		}
+		if dst.Name != want.Name || dst.Count != want.Count || strings.Join(dst.Tags, ",") != "a,b" {
+			t.Errorf("%s: expected %+v, got %+v", contentType, want, dst)
+		}
+	}
+}
+
+func TestBindBodyMultipart(t *testing.T) {
+	for policy, ok := range map[FormPolicy]bool{
+		ParseURLEncoded: true,
+		ParseMultipart:  true,
+		ParseLazily:     true,
+		ParseQuery:      false,
+	} {
+		var (
+			dst bindBodyItem
+			err error
+		)
+		s := NewService("/")
+		s.SetFormPolicy(policy)
+		s.Route(http.MethodPost, "/upload", "", func(w http.ResponseWriter, r *http.Request) {
+			err = BindBody(r, &dst)
+		})
+		s.ServeHTTP(httptest.NewRecorder(), newUploadRequest(t,
+			uploadPart{name: "name", body: "widget"},
+			uploadPart{name: "count", body: "3"},
+		))
+
+		if !ok {
+			if err == nil {
+				t.Errorf("policy %d: expected an error", policy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("policy %d: %v", policy, err)
+		} else if dst.Name != "widget" || dst.Count != 3 {
+			t.Errorf("policy %d: unexpected %+v", policy, dst)
+		}
+	}
+}
+
+func TestBindBodyErrors(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		err         string
+	}{
+		{"application/json", `{"name":"widget","cuont":3}`, `[{"name":"cuont","value":"","type":"","reason":"is not a known parameter"}]`},
+		{"application/json", `{"count":"three"}`, `[{"name":"count","value":"","type":"int","reason":"must be a valid int"}]`},
+		{"application/json", `{"name":`, `[{"name":"body","value":"","type":"","reason":"must be valid JSON: unexpected EOF"}]`},
+		{"application/json", `{} {}`, `[{"name":"body","value":"","type":"","reason":"must contain a single JSON value"}]`},
+		{"application/json", ``, `[{"name":"body","value":"","type":"","reason":"must not be empty"}]`},
+		{"application/xml", `<item><name>widget</name><color>red</color></item>`, `[{"name":"color","value":"","type":"","reason":"is not a known parameter"}]`},
+		{"application/xml", `<item><name>`, `[{"name":"body","value":"","type":"","reason":"must be valid XML: XML syntax error on line 1: unexpected EOF"}]`},
+		{"application/x-www-form-urlencoded", `count=x&color=red`, `[{"name":"color","value":"red","type":"","reason":"is not a known parameter"},` +
+			`{"name":"count","value":"x","type":"int","reason":"must be a valid int"}]`},
+	} {
+		err := BindBody(newBodyRequest(tc.contentType, tc.body), &bindBodyItem{})
+		errs, ok := err.(ParamErrors)
+		if !ok {
+			t.Errorf("%s: expected ParamErrors, got %v", tc.body, err)
+			continue
+		}
+		if b, _ := json.Marshal(errs); string(b) != tc.err {
+			t.Errorf("%s: expected %s, got %s", tc.body, tc.err, b)
+		}
+	}
+
+	if err := BindBody(newBodyRequest("text/plain", "hello"), &bindBodyItem{}); err != ErrUnsupportedMediaType {
+		t.Errorf("expected %v, got %v", ErrUnsupportedMediaType, err)
+	}
+
+	b := NewBodyBinder()
+	b.SetMaxSize(10)
+	if err := b.Bind(newBodyRequest("application/json", `{"name":"a long name"}`), &bindBodyItem{}); err != ErrRequestBodyTooLarge {
+		t.Errorf("expected %v, got %v", ErrRequestBodyTooLarge, err)
+	}
+
+	b = NewBodyBinder()
+	b.AllowUnknownFields()
+	if err := b.Bind(newBodyRequest("application/json", `{"name":"widget","extra":1}`), &bindBodyItem{}); err != nil {
+		t.Errorf("expected unknown fields to be allowed, got %v", err)
+	}
+	if err := b.Bind(newBodyRequest("application/xml", `<item><name>widget</name><extra/></item>`), &bindBodyItem{}); err != nil {
+		t.Errorf("expected unknown elements to be allowed, got %v", err)
+	}
+}
+
+func TestBindBodyXMLNested(t *testing.T) {
+	type part struct {
+		ID  string `xml:"id,attr"`
+		SKU string `xml:"sku"`
+	}
+	type order struct {
+		Customer string `xml:"customer>name"`
+		Parts    []part `xml:"part"`
+		Notes    struct {
+			Text string `xml:",chardata"`
+		} `xml:"notes"`
+	}
+
+	body := `<order><customer><name>Ann</name><vip/></customer><part id="1" color="red"><sku>A1</sku></part>` +
+		`<part id="2"><sku>B2</sku></part><notes>fragile</notes></order>`
+	var dst order
+	if err := BindBody(newBodyRequest("text/xml", body), &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Customer != "Ann" || len(dst.Parts) != 2 || dst.Parts[1].SKU != "B2" || dst.Notes.Text != "fragile" {
+		t.Errorf("unexpected %+v", dst)
+	}
+
+	body = `<order><part id="1"><sku>A1</sku><qty>2</qty></part></order>`
+	err := BindBody(newBodyRequest("text/xml", body), &order{})
+	if want := "bad param 'qty': is not a known parameter"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
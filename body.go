@@ -0,0 +1,120 @@
+package siesta
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// ErrRequestBodyTooLarge is returned when reading past the maximum
+// request body size. It is also the error of the StatusError passed
+// to the error handler in that case.
+var ErrRequestBodyTooLarge = errors.New("siesta: request body too large")
+
+// defaultMaxDrainSize is the default maximum number of unread body
+// bytes discarded after the main handler.
+const defaultMaxDrainSize = 256 << 10
+
+// SetMaxBodySize sets the maximum size of request bodies. Requests
+// declaring a larger Content-Length are answered with 413 Request Entity
+// Too Large through the error handler; reading past the limit in any
+// other case fails with ErrRequestBodyTooLarge and closes the connection,
+// as with http.MaxBytesReader. A size of zero or less
+// removes the limit, which is the default. Routes can override it with
+// the MaxBodySize option.
+func (s *Service) SetMaxBodySize(n int64) {
+	s.maxBodySize = n
+}
+
+// SetMaxDrainSize sets how much of the request body left unread when the
+// response header is written is discarded so the connection can be reused.
+// Connections with more remaining data are closed instead. It defaults to
+// 256 KiB.
+func (s *Service) SetMaxDrainSize(n int64) {
+	s.maxDrainSize = n
+}
+
+// MaxBodySize is a route option that overrides the maximum
+// request body size of the Service. Zero removes the limit.
+func MaxBodySize(n int64) RouteOption {
+	return func(o *routeOptions) {
+		o.maxBodySize = &n
+	}
+}
+
+// limitBody enforces the maximum body size on r. w must be the
+// http.ResponseWriter given by the server, so that exceeding the
+// limit closes the connection. It returns a non-nil error if the
+// request is known to be too large.
+func (s *Service) limitBody(w http.ResponseWriter, r *http.Request, opts *routeOptions) *StatusError {
+	limit := s.maxBodySize
+	if opts != nil && opts.maxBodySize != nil {
+		limit = *opts.maxBodySize
+	}
+	if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	r.Body = &limitedBody{
+		ReadCloser: http.MaxBytesReader(w, r.Body, limit),
+		limit:      limit,
+	}
+	if r.ContentLength > limit {
+		return &StatusError{Status: http.StatusRequestEntityTooLarge, Err: ErrRequestBodyTooLarge}
+	}
+	return nil
+}
+
+// drainBeforeHeader makes rw drain the body of r right before the
+// response header is written, while the connection can still be closed.
+// It returns a function that drains the body if it has not been yet.
+func (s *Service) drainBeforeHeader(rw *responseWriter, r *http.Request) func() {
+	var once sync.Once
+	drain := func() {
+		once.Do(func() {
+			s.drainBody(rw, r)
+		})
+	}
+	rw.beforeHeader(func(status int) {
+		drain()
+	})
+	return drain
+}
+
+// drainBody discards what is left of the request body, up to the maximum
+// drain size, and closes it. The header of w must not have been written.
+// Bodies of responses that close the connection anyway are left alone, as
+// they may still be read by an abandoned handler.
+func (s *Service) drainBody(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil || w.Header().Get("Connection") == "close" {
+		return
+	}
+
+	n, _ := io.CopyN(ioutil.Discard, r.Body, s.maxDrainSize+1)
+	if n > s.maxDrainSize {
+		// Let the server close the connection rather than read
+		// the rest of the body.
+		w.Header().Set("Connection", "close")
+	}
+	r.Body.Close()
+}
+
+// limitedBody reports reads past the limit of an http.MaxBytesReader
+// with ErrRequestBodyTooLarge.
+type limitedBody struct {
+	io.ReadCloser
+
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		err = ErrRequestBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,96 @@
+package siesta
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	s := NewService("limited-body")
+	s.SetMaxBodySize(8)
+	s.Route(http.MethodPost, "/small", "Accepts small bodies", func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write(b)
+	})
+	s.Route(http.MethodPost, "/large", "Accepts large bodies", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}, MaxBodySize(1024))
+
+	for _, test := range []struct {
+		path, body string
+		status     int
+	}{
+		{"/limited-body/small", "12345678", http.StatusOK},
+		{"/limited-body/small", "123456789", http.StatusRequestEntityTooLarge},
+		{"/limited-body/large", "123456789", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body)))
+
+		if want, got := test.status, w.Code; want != got {
+			t.Errorf("%s %q: expected status %d got %d", test.path, test.body, want, got)
+		}
+	}
+}
+
+func TestMaxBodySizeErrorHandler(t *testing.T) {
+	var got error
+	handled := false
+
+	s := NewService("limited-body-errors")
+	s.SetMaxBodySize(4)
+	s.SetErrorHandler(func(c Context, w http.ResponseWriter, r *http.Request) {
+		got, _ = c.Get(ErrorContextKey).(*StatusError)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	s.Route(http.MethodPost, "/", "Accepts tiny bodies", func(w http.ResponseWriter, r *http.Request) {
+		handled = true
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/limited-body-errors", strings.NewReader("12345")))
+
+	if want, got := http.StatusTeapot, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+	if se, ok := got.(*StatusError); !ok || se.Status != http.StatusRequestEntityTooLarge || se.Err != ErrRequestBodyTooLarge {
+		t.Errorf("unexpected error %v", got)
+	}
+	if handled {
+		t.Error("expected the main handler to be skipped")
+	}
+}
+
+func TestMaxDrainSize(t *testing.T) {
+	s := NewService("drained")
+	s.SetMaxDrainSize(4)
+	s.Route(http.MethodPost, "/", "Ignores the body", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	for body, close := range map[string]bool{
+		"1234":                      false,
+		strings.Repeat("x", 100000): true,
+	} {
+		resp, err := http.Post(srv.URL+"/drained", "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if close != resp.Close {
+			t.Errorf("%d bytes: expected close %t got %t", len(body), close, resp.Close)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package siesta
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultSkippedTypes are media types that are already compressed.
+var defaultSkippedTypes = []string{
+	"image/*",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/zstd",
+}
+
+// Compressor compresses responses with gzip or deflate, as negotiated
+// through the Accept-Encoding request header. Its Handle method should
+// be added to the "pre" chain; everything written afterwards is
+// compressed, including the output of the "post" chain.
+//
+// Responses are left alone if they are smaller than the minimum size,
+// already have a Content-Encoding, or have a media type that is already
+// compressed. Routes opt in or out with the Compression route option.
+type Compressor struct {
+	enabled      bool
+	level        int
+	minSize      int
+	skippedTypes []string
+
+	gzipPool sync.Pool
+	zlibPool sync.Pool
+}
+
+// Compression is a route option that enables or disables
+// compression of the route's responses by a Compressor.
+func Compression(enabled bool) RouteOption {
+	return func(o *routeOptions) {
+		o.compress = &enabled
+	}
+}
+
+// NewCompressor returns a Compressor that uses the default compression
+// level and compresses responses of 1024 bytes or more.
+func NewCompressor() *Compressor {
+	return &Compressor{
+		enabled:      true,
+		level:        gzip.DefaultCompression,
+		minSize:      1024,
+		skippedTypes: defaultSkippedTypes,
+	}
+}
+
+// SetDefault sets whether routes without a Compression
+// option are compressed. It defaults to true.
+func (cp *Compressor) SetDefault(enabled bool) {
+	cp.enabled = enabled
+}
+
+// SetLevel sets the compression level, as defined by compress/gzip.
+// It must be called before the Compressor handles any request.
+func (cp *Compressor) SetLevel(level int) {
+	cp.level = level
+}
+
+// SetMinSize sets the minimum size of the responses to compress.
+// Responses that are flushed early are compressed regardless.
+func (cp *Compressor) SetMinSize(n int) {
+	cp.minSize = n
+}
+
+// SkipTypes adds media types whose responses are never compressed.
+// A type may end with "/*" to match all of its subtypes.
+func (cp *Compressor) SkipTypes(types ...string) {
+	cp.skippedTypes = append(cp.skippedTypes[:len(cp.skippedTypes):len(cp.skippedTypes)], types...)
+}
+
+// Handle is a "pre" handler that sets up compression of the response.
+// It has no effect unless w was provided by a Service.
+func (cp *Compressor) Handle(c Context, w http.ResponseWriter, r *http.Request) {
+	rw, ok := w.(*responseWriter)
+	if !ok || rw.Status() != 0 || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+		return
+	}
+	enabled := cp.enabled
+	if opts := routeOptionsFrom(c); opts != nil && opts.compress != nil {
+		enabled = *opts.compress
+	}
+	if !enabled {
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return
+	}
+
+	cw := &compressWriter{
+		ResponseWriter: rw.ResponseWriter,
+		compressor:     cp,
+		encoding:       encoding,
+	}
+	rw.filter(cw, cw.close)
+}
+
+// negotiateEncoding returns the preferred supported encoding
+// in an Accept-Encoding header, or "" if there is none.
+func negotiateEncoding(header string) string {
+	qs := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name := part
+		q := 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qs[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{"gzip", "deflate"} {
+		q, ok := qs[encoding]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+func (cp *Compressor) skipped(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range cp.skippedTypes {
+		if t == mediaType ||
+			(strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			// SVG is text, even though it's an image.
+			return mediaType != "image/svg+xml"
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows
+// whether to compress it, and compresses it if so.
+type compressWriter struct {
+	http.ResponseWriter
+
+	compressor *Compressor
+	encoding   string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.compressor.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(false); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide sends the header and the buffered output, compressed or not.
+// Short responses are compressed only if force is set.
+func (w *compressWriter) decide(force bool) error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if bodyAllowed(w.status) && h.Get("Content-Encoding") == "" &&
+		(force || len(w.buf) >= w.compressor.minSize) &&
+		!w.compressor.skipped(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		w.enc = w.compressor.writer(w.encoding, w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.compressor.release(w.encoding, w.enc)
+		w.enc = nil
+	}
+}
+
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// writer returns a pooled encoder writing to dst.
+func (cp *Compressor) writer(encoding string, dst io.Writer) io.WriteCloser {
+	if encoding == "gzip" {
+		if gz, ok := cp.gzipPool.Get().(*gzip.Writer); ok {
+			gz.Reset(dst)
+			return gz
+		}
+		gz, err := gzip.NewWriterLevel(dst, cp.level)
+		if err != nil {
+			gz = gzip.NewWriter(dst)
+		}
+		return gz
+	}
+
+	// The "deflate" content coding is the zlib format.
+	if zw, ok := cp.zlibPool.Get().(*zlib.Writer); ok {
+		zw.Reset(dst)
+		return zw
+	}
+	zw, err := zlib.NewWriterLevel(dst, cp.level)
+	if err != nil {
+		zw = zlib.NewWriter(dst)
+	}
+	return zw
+}
+
+// release returns a closed encoder to its pool.
+func (cp *Compressor) release(encoding string, enc io.WriteCloser) {
+	if encoding == "gzip" {
+		cp.gzipPool.Put(enc)
+	} else {
+		cp.zlibPool.Put(enc)
+	}
+}
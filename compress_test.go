@@ -0,0 +1,129 @@
+package siesta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                         "",
+		"identity":                 "",
+		"gzip":                     "gzip",
+		"deflate, gzip":            "gzip",
+		"gzip;q=0.5, deflate":      "deflate",
+		"gzip;q=0, deflate;q=0":    "",
+		"*":                        "gzip",
+		"gzip;q=0, *;q=0.1":        "deflate",
+		" GZIP ; q=1.0 , br;q=0.9": "gzip",
+	} {
+		if got := negotiateEncoding(header); want != got {
+			t.Errorf("%q: expected %q got %q", header, want, got)
+		}
+	}
+}
+
+func TestCompressor(t *testing.T) {
+	body := strings.Repeat(`{"hello":"world"}`, 100)
+
+	s := NewService("compressed")
+	s.AddPre(NewCompressor().Handle)
+	s.Route(http.MethodGet, "/large", "Large JSON", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+	s.Route(http.MethodGet, "/small", "Small JSON", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	s.Route(http.MethodGet, "/image", "Image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(body))
+	})
+	s.Route(http.MethodGet, "/download", "Download", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}, Compression(false))
+
+	for _, test := range []struct {
+		path, acceptEncoding, contentEncoding string
+	}{
+		{"/compressed/large", "gzip", "gzip"},
+		{"/compressed/large", "deflate", "deflate"},
+		{"/compressed/large", "", ""},
+		{"/compressed/small", "gzip", ""},
+		{"/compressed/image", "gzip", ""},
+		{"/compressed/download", "gzip", ""},
+	} {
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+
+		if want, got := test.contentEncoding, w.Header().Get("Content-Encoding"); want != got {
+			t.Errorf("%s %q: expected Content-Encoding %q got %q", test.path, test.acceptEncoding, want, got)
+			continue
+		}
+
+		var got []byte
+		switch test.contentEncoding {
+		case "gzip":
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ = ioutil.ReadAll(zr)
+		case "deflate":
+			zr, err := zlib.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ = ioutil.ReadAll(zr)
+		default:
+			got = w.Body.Bytes()
+		}
+		if test.path != "/compressed/small" && !bytes.Equal(got, []byte(body)) {
+			t.Errorf("%s %q: unexpected body %q", test.path, test.acceptEncoding, got)
+		}
+
+		vary := w.Header().Get("Vary")
+		if test.path == "/compressed/download" {
+			if vary != "" {
+				t.Errorf("%s: expected no Vary header got %q", test.path, vary)
+			}
+		} else if vary != "Accept-Encoding" {
+			t.Errorf("%s: expected Vary header got %q", test.path, vary)
+		}
+	}
+}
+
+func TestCompressorFlush(t *testing.T) {
+	s := NewService("streamed")
+	s.AddPre(NewCompressor().Handle)
+	s.Route(http.MethodGet, "/events", "Streams events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/streamed/events", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if !w.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ioutil.ReadAll(zr)
+	if want := "data: 1\n\n"; want != string(got) {
+		t.Errorf("expected body %q got %q", want, got)
+	}
+}
@@ -0,0 +1,96 @@
+package siesta
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrOverloaded is the error of the StatusError passed to the error
+// handler when a request is shed by a concurrency limit.
+var ErrOverloaded = errors.New("siesta: too many concurrent requests")
+
+// SetMaxConcurrency limits the number of main handlers of s running at
+// once to n. Requests over the limit wait for up to wait, and are answered
+// with 503 Service Unavailable and a Retry-After header through the error
+// handler if no handler finishes in time. Handlers abandoned after their
+// timeout count until they return. Zero removes the limit, which is the
+// default. Routes can override it with the MaxConcurrency option.
+func (s *Service) SetMaxConcurrency(n int, wait time.Duration) {
+	s.concurrency = newConcurrencyLimit(n, wait)
+}
+
+// MaxConcurrency is a route option that gives the route its own limit of
+// n requests at once, overriding the limit of the Service. Requests over
+// the limit wait as described in Service.SetMaxConcurrency. Zero leaves
+// the route unlimited.
+func MaxConcurrency(n int, wait time.Duration) RouteOption {
+	limit := newConcurrencyLimit(n, wait)
+	return func(o *routeOptions) {
+		o.concurrency = limit
+		o.hasConcurrency = true
+	}
+}
+
+// concurrencyFor returns the concurrency limit of the route with opts,
+// or nil if it is unlimited.
+func (s *Service) concurrencyFor(opts *routeOptions) *concurrencyLimit {
+	if opts != nil && opts.hasConcurrency {
+		return opts.concurrency
+	}
+	return s.concurrency
+}
+
+// concurrencyLimit is a semaphore with a bounded wait.
+type concurrencyLimit struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+func newConcurrencyLimit(n int, wait time.Duration) *concurrencyLimit {
+	if n <= 0 {
+		return nil
+	}
+	return &concurrencyLimit{
+		slots: make(chan struct{}, n),
+		wait:  wait,
+	}
+}
+
+// acquire takes a slot for r. It returns false if none
+// was freed within the wait, or r was canceled.
+func (l *concurrencyLimit) acquire(r *http.Request) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func (l *concurrencyLimit) release() {
+	<-l.slots
+}
+
+// retryAfter returns the value of the Retry-After header of shed requests.
+func (l *concurrencyLimit) retryAfter() string {
+	if seconds := ceilSeconds(l.wait); seconds > 1 {
+		return strconv.Itoa(seconds)
+	}
+	return "1"
+}
@@ -0,0 +1,95 @@
+package siesta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+
+	s := NewService("concurrency")
+	s.SetMaxConcurrency(1, 0)
+	s.Route(http.MethodGet, "/report", "Expensive report", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}, MaxConcurrency(2, 10*time.Millisecond))
+	s.Route(http.MethodGet, "/health", "Health check", func(w http.ResponseWriter, r *http.Request) {},
+		MaxConcurrency(0, 0))
+	s.Route(http.MethodGet, "/other", "Other route", func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/concurrency/report", nil))
+		}()
+	}
+	<-started
+	<-started
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/concurrency/report", nil))
+	if want, got := http.StatusServiceUnavailable, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+	if want, got := "1", w.Header().Get("Retry-After"); want != got {
+		t.Errorf("expected Retry-After %q got %q", want, got)
+	}
+
+	for _, path := range []string{"/concurrency/health", "/concurrency/other"} {
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if want, got := http.StatusOK, w.Code; want != got {
+			t.Errorf("%s: expected status %d got %d", path, want, got)
+		}
+	}
+
+	close(release)
+	wg.Wait()
+}
+
+func TestMaxConcurrencyAbandonedHandler(t *testing.T) {
+	unblock := make(chan struct{})
+	exited := make(chan struct{}, 3)
+
+	s := NewService("abandoned-concurrency")
+	s.SetMaxConcurrency(1, 0)
+	s.SetTimeout(10 * time.Millisecond)
+	s.Route(http.MethodGet, "/slow", "Ignores its deadline", func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		exited <- struct{}{}
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abandoned-concurrency/slow", nil))
+	if want, got := http.StatusServiceUnavailable, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+
+	// The abandoned handler still holds the only slot.
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abandoned-concurrency/slow", nil))
+	if want, got := "1", w.Header().Get("Retry-After"); want != got {
+		t.Errorf("expected the request to be shed, got Retry-After %q", got)
+	}
+
+	close(unblock)
+	<-exited
+
+	// The slot is released right after the handler returns.
+	for i := 0; i < 100; i++ {
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abandoned-concurrency/slow", nil))
+		if w.Code == http.StatusOK {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("expected the slot to be released, got status %d", w.Code)
+}
@@ -0,0 +1,315 @@
+package siesta
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ParamError describes a parameter with an invalid value.
+type ParamError struct {
+	// Name is the name of the parameter.
+	Name string `json:"name"`
+	// Value is the value given, with multiple values separated by commas.
+	Value string `json:"value"`
+	// Type is the type of the parameter, as reported by Params.Usage.
+	Type string `json:"type"`
+	// Reason describes the problem, as in "must be at most 100".
+	Reason string `json:"reason"`
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("bad param '%s': %s", e.Name, e.Reason)
+}
+
+// ParamErrors is the list of problems found by a Params that collects
+// errors, sorted by parameter name. It marshals to a JSON array of
+// objects with the name, value, type and reason of each problem.
+type ParamErrors []*ParamError
+
+func (e ParamErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// invalidParam returns the error for a value of v that can't be parsed.
+func invalidParam(name, value string, v flag.Value) *ParamError {
+	typ := paramTypeName(v)
+	reason := "must be a valid " + typ
+	if strings.HasPrefix(typ, "[]") {
+		reason = "must be a comma-separated list of " + typ[2:]
+	}
+	return &ParamError{
+		Name:   name,
+		Value:  value,
+		Type:   typ,
+		Reason: reason,
+	}
+}
+
+// Constraint restricts the values of a parameter. Constraints on the
+// values of a parameter apply to every element of the SliceXXX types.
+type Constraint struct {
+	desc  string
+	check func(v interface{}) string
+	whole bool
+}
+
+// Constrain adds constraints to a defined parameter. Parse checks them
+// against the given values, but not against defaults, and returns a
+// *ParamError for the first one that fails, unless errors are collected.
+// Usage describes them.
+func (rp *Params) Constrain(name string, constraints ...Constraint) {
+	if rp.constraints == nil {
+		rp.constraints = map[string][]Constraint{}
+	}
+	rp.constraints[name] = append(rp.constraints[name], constraints...)
+}
+
+// Min requires numeric values to be at least n. Durations are
+// compared in seconds.
+func Min(n float64) Constraint {
+	return Constraint{
+		desc: "min " + formatFloat(n),
+		check: func(v interface{}) string {
+			if f, ok := toFloat(v); ok && f < n {
+				return "must be at least " + formatBound(n, v)
+			}
+			return ""
+		},
+	}
+}
+
+// Max requires numeric values to be at most n. Durations are
+// compared in seconds.
+func Max(n float64) Constraint {
+	return Constraint{
+		desc: "max " + formatFloat(n),
+		check: func(v interface{}) string {
+			if f, ok := toFloat(v); ok && f > n {
+				return "must be at most " + formatBound(n, v)
+			}
+			return ""
+		},
+	}
+}
+
+// MinLength requires string values to have at least n characters.
+func MinLength(n int) Constraint {
+	return Constraint{
+		desc: "min length " + strconv.Itoa(n),
+		check: func(v interface{}) string {
+			if s, ok := v.(string); ok && utf8.RuneCountInString(s) < n {
+				return fmt.Sprintf("must be at least %d characters long", n)
+			}
+			return ""
+		},
+	}
+}
+
+// MaxLength requires string values to have at most n characters.
+func MaxLength(n int) Constraint {
+	return Constraint{
+		desc: "max length " + strconv.Itoa(n),
+		check: func(v interface{}) string {
+			if s, ok := v.(string); ok && utf8.RuneCountInString(s) > n {
+				return fmt.Sprintf("must be at most %d characters long", n)
+			}
+			return ""
+		},
+	}
+}
+
+// Pattern requires string values to match the regular expression expr
+// entirely. It panics if expr can't be compiled.
+func Pattern(expr string) Constraint {
+	re := regexp.MustCompile("^(?:" + expr + ")$")
+	return Constraint{
+		desc: "pattern " + expr,
+		check: func(v interface{}) string {
+			if s, ok := v.(string); ok && !re.MatchString(s) {
+				return "must match " + expr
+			}
+			return ""
+		},
+	}
+}
+
+// OneOf requires values to be one of values, as formatted by fmt.Sprint.
+func OneOf(values ...string) Constraint {
+	return Constraint{
+		desc: "one of " + strings.Join(values, "|"),
+		check: func(v interface{}) string {
+			if !containsString(values, fmt.Sprint(v)) {
+				return "must be one of " + strings.Join(values, ", ")
+			}
+			return ""
+		},
+	}
+}
+
+// MinItems requires SliceXXX parameters to have at least n values.
+func MinItems(n int) Constraint {
+	return Constraint{
+		desc:  "min items " + strconv.Itoa(n),
+		whole: true,
+		check: func(v interface{}) string {
+			if items, ok := countItems(v); ok && items < n {
+				return fmt.Sprintf("must have at least %d values", n)
+			}
+			return ""
+		},
+	}
+}
+
+// MaxItems requires SliceXXX parameters to have at most n values.
+func MaxItems(n int) Constraint {
+	return Constraint{
+		desc:  "max items " + strconv.Itoa(n),
+		whole: true,
+		check: func(v interface{}) string {
+			if items, ok := countItems(v); ok && items > n {
+				return fmt.Sprintf("must have at most %d values", n)
+			}
+			return ""
+		},
+	}
+}
+
+// Validate requires f to accept the value of the parameter. For the
+// SliceXXX types, f gets the whole slice, as in []int. The error
+// returned by f is the reason of the *ParamError. desc describes
+// the constraint in the usage.
+func Validate(desc string, f func(v interface{}) error) Constraint {
+	return Constraint{
+		desc:  desc,
+		whole: true,
+		check: func(v interface{}) string {
+			if err := f(v); err != nil {
+				return err.Error()
+			}
+			return ""
+		},
+	}
+}
+
+// validate checks the constraints of the given parameters, and returns
+// an error for each parameter that fails them.
+func (rp *Params) validate(args url.Values) ParamErrors {
+	var errs ParamErrors
+	for _, name := range sortedNames(rp.constraints) {
+		if !rp.set[name] {
+			continue
+		}
+		f := rp.fset.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if reason := checkConstraints(rp.constraints[name], paramValue(f.Value)); reason != "" {
+			errs = append(errs, &ParamError{
+				Name:   name,
+				Value:  strings.Join(args[name], ","),
+				Type:   paramTypeName(f.Value),
+				Reason: reason,
+			})
+		}
+	}
+	return errs
+}
+
+// checkConstraints returns the reason the first failing constraint
+// rejects v, or "" if they all accept it.
+func checkConstraints(constraints []Constraint, v interface{}) string {
+	for _, c := range constraints {
+		rv := reflect.ValueOf(v)
+		if c.whole || rv.Kind() != reflect.Slice {
+			if reason := c.check(v); reason != "" {
+				return reason
+			}
+			continue
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if reason := c.check(rv.Index(i).Interface()); reason != "" {
+				return reason
+			}
+		}
+	}
+	return ""
+}
+
+func sortedNames(constraints map[string][]Constraint) []string {
+	names := make([]string, 0, len(constraints))
+	for name := range constraints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// describeConstraints returns the descriptions of constraints.
+func describeConstraints(constraints []Constraint) []string {
+	descs := make([]string, 0, len(constraints))
+	for _, c := range constraints {
+		descs = append(descs, c.desc)
+	}
+	return descs
+}
+
+// paramValue returns the value of a parameter, such
+// as an int or, for the SliceXXX types, an []int.
+func paramValue(v flag.Value) interface{} {
+	if g, ok := v.(flag.Getter); ok {
+		return g.Get()
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Slice {
+		// Drop the name of the type, as in SInt.
+		elem := rv.Elem()
+		return elem.Convert(reflect.SliceOf(elem.Type().Elem())).Interface()
+	}
+	return v
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case time.Duration:
+		return n.Seconds(), true
+	}
+	return 0, false
+}
+
+// formatBound formats the bound n for values like v.
+func formatBound(n float64, v interface{}) string {
+	if _, ok := v.(time.Duration); ok {
+		return time.Duration(n * float64(time.Second)).String()
+	}
+	return formatFloat(n)
+}
+
+func countItems(v interface{}) (int, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return 0, false
+	}
+	return rv.Len(), true
+}
@@ -0,0 +1,128 @@
+package siesta
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParamsConstraints(t *testing.T) {
+	newParams := func() *Params {
+		p := &Params{}
+		p.Int("limit", 20, "max results")
+		p.Constrain("limit", Min(1), Max(100))
+		p.String("name", "", "resource name")
+		p.Constrain("name", MinLength(2), MaxLength(5), Pattern("[a-z]+"))
+		p.String("order", "asc", "sort order")
+		p.Constrain("order", OneOf("asc", "desc"))
+		p.SliceInt("ids", 0, "resource identifiers")
+		p.Constrain("ids", MinItems(1), MaxItems(3), Min(1))
+		p.Duration("wait", 0, "wait time")
+		p.Constrain("wait", Min(0.5), Max(10))
+		p.SliceDuration("delays", 0, "delays")
+		p.Constrain("delays", Min(1))
+		p.Float64("ratio", 0, "a ratio")
+		p.Constrain("ratio", Validate("not 0.5", func(v interface{}) error {
+			if v.(float64) == 0.5 {
+				return errors.New("must not be 0.5")
+			}
+			return nil
+		}))
+		return p
+	}
+
+	for _, tc := range []struct {
+		query  string
+		name   string
+		reason string
+	}{
+		{"limit=50&name=abc&order=desc&ids=1,2&ratio=0.1", "", ""},
+		{"order=desc", "", ""},
+		{"limit=0", "limit", "must be at least 1"},
+		{"limit=101", "limit", "must be at most 100"},
+		{"name=a", "name", "must be at least 2 characters long"},
+		{"name=abcdef", "name", "must be at most 5 characters long"},
+		{"name=ab1", "name", "must match [a-z]+"},
+		{"order=up", "order", "must be one of asc, desc"},
+		{"ids=1,2,3,4", "ids", "must have at most 3 values"},
+		{"ids=1&ids=0", "ids", "must be at least 1"},
+		{"ratio=0.5", "ratio", "must not be 0.5"},
+		{"wait=1s&delays=1s,1m", "", ""},
+		{"wait=1ns", "wait", "must be at least 500ms"},
+		{"wait=11s", "wait", "must be at most 10s"},
+		{"delays=1s,999ms", "delays", "must be at least 1s"},
+	} {
+		v, _ := url.ParseQuery(tc.query)
+		err := newParams().Parse(v)
+		if tc.reason == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.query, err)
+			}
+			continue
+		}
+		pe, ok := err.(*ParamError)
+		if !ok {
+			t.Errorf("%s: expected a *ParamError, got %v", tc.query, err)
+			continue
+		}
+		if pe.Name != tc.name || pe.Reason != tc.reason {
+			t.Errorf("%s: expected %s %q, got %s %q", tc.query, tc.name, tc.reason, pe.Name, pe.Reason)
+		}
+	}
+
+	v, _ := url.ParseQuery("ids=1&ids=0")
+	err := newParams().Parse(v).(*ParamError)
+	if want := (ParamError{Name: "ids", Value: "1,0", Type: "[]int", Reason: "must be at least 1"}); *err != want {
+		t.Errorf("expected %+v, got %+v", want, *err)
+	}
+	if want, got := "bad param 'ids': must be at least 1", err.Error(); want != got {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	usage := newParams().Usage()
+	if want, got := "max results (min 1, max 100)", usage["limit"][2]; want != got {
+		t.Errorf("expected usage %q, got %q", want, got)
+	}
+	if want, got := "resource identifiers (min items 1, max items 3, min 1)", usage["ids"][2]; want != got {
+		t.Errorf("expected usage %q, got %q", want, got)
+	}
+}
+
+func TestParamsCollectErrors(t *testing.T) {
+	p := &Params{}
+	p.SetStrict(true)
+	p.SetCollectErrors(true)
+	p.Int("limit", 20, "max results")
+	p.Constrain("limit", Max(100))
+	p.SliceInt("ids", 0, "resource identifiers")
+	p.String("name", "", "resource name")
+	p.Constrain("name", MinLength(2))
+	p.Int("id", 0, "resource identifier")
+	p.Required("id")
+
+	v, _ := url.ParseQuery("limt=10&ids=1,x&name=a")
+	err := p.Parse(v)
+	errs, ok := err.(ParamErrors)
+	if !ok {
+		t.Fatalf("expected ParamErrors, got %v", err)
+	}
+
+	b, _ := json.Marshal(errs)
+	want := `[{"name":"id","value":"","type":"int","reason":"is required"},` +
+		`{"name":"ids","value":"1,x","type":"[]int","reason":"must be a comma-separated list of int"},` +
+		`{"name":"limt","value":"10","type":"","reason":"is not a known parameter"},` +
+		`{"name":"name","value":"a","type":"string","reason":"must be at least 2 characters long"}]`
+	if string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+	if !strings.HasPrefix(err.Error(), "bad param 'id': is required; bad param 'ids':") {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+
+	v, _ = url.ParseQuery("id=1&limit=50")
+	if err := p.Parse(v); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
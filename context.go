@@ -7,6 +7,26 @@ const nullByteStr = "\x00"
 // within a handler.
 const UsageContextKey = nullByteStr + "usage"
 
+// RouteContextKey is a special context key to get the pattern of the matched
+// route (e.g. "/resources/:resourceID") within a handler.
+const RouteContextKey = nullByteStr + "route"
+
+// RequestIDContextKey is a special context key to get the request ID
+// assigned by a RequestIdentifier.
+const RequestIDContextKey = nullByteStr + "request-id"
+
+// TraceIDContextKey is a special context key to get the trace ID
+// of the request when the Service has a Tracer.
+const TraceIDContextKey = nullByteStr + "trace-id"
+
+// TimingsContextKey is a special context key to get the []HandlerTiming
+// of the handlers run so far when the Service has timings enabled.
+const TimingsContextKey = nullByteStr + "timings"
+
+// ErrorContextKey is a special context key to get the *StatusError
+// within the error handler of a Service.
+const ErrorContextKey = nullByteStr + "error"
+
 // Context is a context interface that gets passed to each ContextHandler.
 type Context interface {
 	Set(string, interface{})
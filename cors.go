@@ -0,0 +1,162 @@
+package siesta
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS handles Cross-Origin Resource Sharing for a Service.
+// Preflight requests are answered with the verbs registered
+// for the requested path, so routes added later are picked up
+// automatically.
+//
+// Its Handle method should be added to the "pre" chain before
+// any authentication handler, since browsers don't send
+// credentials with preflight requests.
+type CORS struct {
+	service *Service
+
+	anyOrigin        bool
+	origins          map[string]bool
+	originPatterns   [][2]string
+	allowedHeaders   []string
+	exposedHeaders   []string
+	allowCredentials bool
+	maxAge           time.Duration
+}
+
+// NewCORS returns a CORS handler for s. No origins are allowed
+// until AllowOrigins is called.
+func NewCORS(s *Service) *CORS {
+	return &CORS{
+		service: s,
+		origins: map[string]bool{},
+	}
+}
+
+// AllowOrigins adds origins to the list of allowed origins.
+// An origin may contain a single "*" wildcard, as in
+// "https://*.example.com". The origin "*" allows every origin.
+func (cr *CORS) AllowOrigins(origins ...string) {
+	for _, origin := range origins {
+		switch i := strings.IndexByte(origin, '*'); {
+		case origin == "*":
+			cr.anyOrigin = true
+		case i >= 0:
+			cr.originPatterns = append(cr.originPatterns, [2]string{origin[:i], origin[i+1:]})
+		default:
+			cr.origins[origin] = true
+		}
+	}
+}
+
+// AllowHeaders sets the request headers allowed in cross-origin requests.
+// If none are set, the headers requested in a preflight request are allowed.
+func (cr *CORS) AllowHeaders(headers ...string) {
+	cr.allowedHeaders = append(cr.allowedHeaders, headers...)
+}
+
+// ExposeHeaders sets the response headers exposed to cross-origin requests.
+func (cr *CORS) ExposeHeaders(headers ...string) {
+	cr.exposedHeaders = append(cr.exposedHeaders, headers...)
+}
+
+// AllowCredentials allows cross-origin requests to include credentials.
+func (cr *CORS) AllowCredentials() {
+	cr.allowCredentials = true
+}
+
+// SetMaxAge sets how long the result of a preflight request can be cached.
+func (cr *CORS) SetMaxAge(d time.Duration) {
+	cr.maxAge = d
+}
+
+// Handle is a "pre" handler that adds CORS headers to the response.
+// It answers preflight requests itself and quits.
+func (cr *CORS) Handle(c Context, w http.ResponseWriter, r *http.Request, quit func()) {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	if preflight {
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if origin == "" {
+		return
+	}
+
+	if !preflight {
+		if cr.allowed(origin) {
+			cr.setOrigin(h, origin)
+			if len(cr.exposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(cr.exposedHeaders, ", "))
+			}
+		}
+		return
+	}
+
+	methods := cr.service.methods(r.URL.Path)
+	if len(methods) == 0 {
+		// Let the Service respond as it would to any unknown path.
+		return
+	}
+
+	defer quit()
+
+	requested := r.Header.Get("Access-Control-Request-Method")
+	if !cr.allowed(origin) || !containsString(methods, requested) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	cr.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(cr.allowedHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(cr.allowedHeaders, ", "))
+	} else if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		h.Set("Access-Control-Allow-Headers", headers)
+	}
+	if cr.maxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(cr.maxAge/time.Second)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cr *CORS) allowed(origin string) bool {
+	if cr.anyOrigin || cr.origins[origin] {
+		return true
+	}
+	for _, p := range cr.originPatterns {
+		if len(origin) >= len(p[0])+len(p[1]) &&
+			strings.HasPrefix(origin, p[0]) && strings.HasSuffix(origin, p[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cr *CORS) setOrigin(h http.Header, origin string) {
+	if cr.anyOrigin && !cr.allowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if cr.allowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package siesta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newCORSTestService() *Service {
+	s := NewService("cors")
+	cors := NewCORS(s)
+	cors.AllowOrigins("https://app.example.com", "https://*.example.org")
+	cors.AllowCredentials()
+	cors.ExposeHeaders("X-Request-ID")
+	cors.SetMaxAge(10 * time.Minute)
+	s.AddPre(cors.Handle)
+
+	s.Route(http.MethodGet, "/items/:id", "Gets an item", func(w http.ResponseWriter, r *http.Request) {})
+	s.Route(http.MethodDelete, "/items/:id", "Deletes an item", func(w http.ResponseWriter, r *http.Request) {})
+	s.Route(http.MethodPost, "/items", "Creates an item", func(w http.ResponseWriter, r *http.Request) {})
+	return s
+}
+
+func TestCORSPreflight(t *testing.T) {
+	s := newCORSTestService()
+
+	r := httptest.NewRequest(http.MethodOptions, "/cors/items/1/", nil)
+	r.Header.Set("Origin", "https://api.example.org")
+	r.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	r.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if want, got := http.StatusNoContent, w.Code; want != got {
+		t.Fatalf("expected status %d got %d", want, got)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://api.example.org",
+		"Access-Control-Allow-Methods":     "DELETE, GET",
+		"Access-Control-Allow-Headers":     "Content-Type",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+	} {
+		if got := w.Header().Get(header); want != got {
+			t.Errorf("%s: expected %q got %q", header, want, got)
+		}
+	}
+	if want, got := 3, len(w.Header()["Vary"]); want != got {
+		t.Errorf("expected %d Vary headers got %d", want, got)
+	}
+}
+
+func TestCORSPreflightDisallowed(t *testing.T) {
+	s := newCORSTestService()
+
+	for _, test := range []struct {
+		origin, method string
+	}{
+		{"https://evil.example.com", http.MethodGet},
+		{"https://app.example.com", http.MethodPut},
+	} {
+		r := httptest.NewRequest(http.MethodOptions, "/cors/items/1", nil)
+		r.Header.Set("Origin", test.origin)
+		r.Header.Set("Access-Control-Request-Method", test.method)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s %s: expected no allowed origin, got %q", test.origin, test.method, got)
+		}
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	s := newCORSTestService()
+
+	r := httptest.NewRequest(http.MethodGet, "/cors/items/1", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if want, got := http.StatusOK, w.Code; want != got {
+		t.Fatalf("expected status %d got %d", want, got)
+	}
+	if want, got := "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"); want != got {
+		t.Errorf("expected origin %q got %q", want, got)
+	}
+	if want, got := "X-Request-ID", w.Header().Get("Access-Control-Expose-Headers"); want != got {
+		t.Errorf("expected exposed headers %q got %q", want, got)
+	}
+	if want, got := "Origin", w.Header().Get("Vary"); want != got {
+		t.Errorf("expected Vary %q got %q", want, got)
+	}
+}
@@ -0,0 +1,116 @@
+package siesta
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrDecompressedBodyTooLarge is returned when reading a request body
+// that decompresses to more than the limit set with
+// Service.EnableRequestDecompression.
+var ErrDecompressedBodyTooLarge = errors.New("siesta: decompressed request body too large")
+
+// EnableRequestDecompression makes s transparently decode request bodies
+// sent with a gzip or deflate Content-Encoding, before parsing forms and
+// running any handler. Reading more than maxSize decompressed bytes fails
+// with ErrDecompressedBodyTooLarge, which is answered with 413 Request
+// Entity Too Large when parsing forms. Bodies with other encodings are
+// left untouched.
+func (s *Service) EnableRequestDecompression(maxSize int64) {
+	s.maxDecompressedSize = maxSize
+}
+
+// decompressRequest replaces the body of r with a decoding reader
+// if it has a supported Content-Encoding.
+func (s *Service) decompressRequest(r *http.Request) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "gzip", "x-gzip", "deflate":
+	default:
+		return
+	}
+
+	r.Body = &decompressingBody{
+		src:      r.Body,
+		encoding: encoding,
+		limit:    s.maxDecompressedSize,
+	}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+}
+
+// decompressingBody decodes a request body as it is read. The
+// decoder is set up on the first read so that a malformed body
+// fails like any other read error.
+type decompressingBody struct {
+	src      io.ReadCloser
+	encoding string
+	limit    int64
+
+	dec  io.ReadCloser
+	read int64
+	err  error
+}
+
+func (b *decompressingBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.dec == nil {
+		if b.dec, b.err = newBodyDecoder(b.encoding, b.src); b.err != nil {
+			return 0, b.err
+		}
+	}
+
+	// Read one byte past the limit to tell whether it was exceeded.
+	if max := b.limit - b.read + 1; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := b.dec.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		n -= int(b.read - b.limit)
+		b.read = b.limit
+		err = ErrDecompressedBodyTooLarge
+	}
+	if err != nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *decompressingBody) Close() error {
+	if b.dec != nil {
+		b.dec.Close()
+	}
+	return b.src.Close()
+}
+
+func newBodyDecoder(encoding string, src io.Reader) (io.ReadCloser, error) {
+	if encoding != "deflate" {
+		return gzip.NewReader(src)
+	}
+
+	// "deflate" is meant to be the zlib format, but some
+	// clients send raw deflate data instead.
+	br := bufio.NewReader(src)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,98 @@
+package siesta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestDecompression(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("name=siesta"))
+	zw.Close()
+
+	var zl bytes.Buffer
+	zlw := zlib.NewWriter(&zl)
+	zlw.Write([]byte("name=siesta"))
+	zlw.Close()
+
+	s := NewService("decompressed")
+	s.EnableRequestDecompression(1 << 10)
+	s.Route(http.MethodPost, "/form", "Reads a form", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Form.Get("name")))
+	})
+
+	for encoding, body := range map[string][]byte{
+		"gzip":    gz.Bytes(),
+		"deflate": zl.Bytes(),
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/decompressed/form", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Content-Encoding", encoding)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+
+		if want, got := "siesta", w.Body.String(); want != got {
+			t.Errorf("%s: expected %q got %q", encoding, want, got)
+		}
+	}
+}
+
+func TestRequestDecompressionLimit(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(strings.Repeat("a", 2048)))
+	zw.Close()
+
+	var (
+		n   int
+		err error
+	)
+	s := NewService("bomb")
+	s.EnableRequestDecompression(1 << 10)
+	s.Route(http.MethodPost, "/upload", "Uploads data", func(w http.ResponseWriter, r *http.Request) {
+		var b []byte
+		b, err = ioutil.ReadAll(r.Body)
+		n = len(b)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/bomb/upload", &gz)
+	r.Header.Set("Content-Encoding", "gzip")
+	s.ServeHTTP(httptest.NewRecorder(), r)
+
+	if want, got := ErrDecompressedBodyTooLarge, err; want != got {
+		t.Errorf("expected error %v got %v", want, got)
+	}
+	if want, got := 1<<10, n; want != got {
+		t.Errorf("expected %d bytes got %d", want, got)
+	}
+}
+
+func TestRequestDecompressionLimitForm(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("name=" + strings.Repeat("a", 2048)))
+	zw.Close()
+
+	s := NewService("bomb")
+	s.EnableRequestDecompression(1 << 10)
+	s.Route(http.MethodPost, "/form", "Reads a form", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected the handler not to run")
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/bomb/form", &gz)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if want, got := http.StatusRequestEntityTooLarge, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+}
@@ -0,0 +1,181 @@
+package siesta
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// drainState tracks the requests in flight in a Service.
+type drainState struct {
+	mu       sync.Mutex
+	inFlight int
+	draining bool
+	idle     chan struct{}
+
+	shutdownHooks []func(ctx context.Context) error
+
+	// healths fail readiness for delay before draining starts
+	healths []*Health
+	delay   time.Duration
+}
+
+// SetDrainDelay sets how long Drain keeps serving requests after making
+// the readiness checks of the Health endpoints of s fail, so that load
+// balancers can stop sending requests before s refuses them. It defaults
+// to zero.
+func (s *Service) SetDrainDelay(d time.Duration) {
+	s.drain.mu.Lock()
+	s.drain.delay = d
+	s.drain.mu.Unlock()
+}
+
+// addHealth registers h to be shut down by Drain.
+func (s *Service) addHealth(h *Health) {
+	s.drain.mu.Lock()
+	s.drain.healths = append(s.drain.healths, h)
+	s.drain.mu.Unlock()
+}
+
+// OnShutdown adds a hook that Drain runs once there are no requests in
+// flight, such as closing a database. Hooks run in the order they were added.
+func (s *Service) OnShutdown(f func(ctx context.Context) error) {
+	s.drain.mu.Lock()
+	s.drain.shutdownHooks = append(s.drain.shutdownHooks, f)
+	s.drain.mu.Unlock()
+}
+
+// InFlight returns the number of requests s is serving.
+func (s *Service) InFlight() int {
+	s.drain.mu.Lock()
+	defer s.drain.mu.Unlock()
+	return s.drain.inFlight
+}
+
+// Drain shuts down the Health endpoints added to s with Health.AddRoutes,
+// so that readiness fails, and waits for the drain delay. It then stops s
+// from serving new requests, which are answered with 503 Service
+// Unavailable and "Connection: close" through the error handler, waits
+// for the requests in flight to finish, including handlers abandoned after
+// their timeout, and runs the shutdown hooks. It returns the error of ctx
+// if it is done first, or the first error returned by a hook.
+func (s *Service) Drain(ctx context.Context) error {
+	d := &s.drain
+	d.mu.Lock()
+	healths := d.healths
+	delay := d.delay
+	d.mu.Unlock()
+
+	for _, h := range healths {
+		h.Shutdown()
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	d.mu.Lock()
+	d.draining = true
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+		if d.inFlight == 0 {
+			close(d.idle)
+		}
+	}
+	idle := d.idle
+	hooks := d.shutdownHooks
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var firstErr error
+	for _, f := range hooks {
+		if err := f(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// startRequest counts a new request in flight. It returns
+// false if s is draining.
+func (s *Service) startRequest() bool {
+	d := &s.drain
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inFlight++
+	return true
+}
+
+// holdRequest counts a handler of a request in flight, which may run
+// past the end of the request, even if s is draining.
+func (s *Service) holdRequest() {
+	d := &s.drain
+	d.mu.Lock()
+	d.inFlight++
+	d.mu.Unlock()
+}
+
+// endRequest counts the end of a request in flight.
+func (s *Service) endRequest() {
+	d := &s.drain
+	d.mu.Lock()
+	d.inFlight--
+	if d.inFlight == 0 && d.idle != nil {
+		close(d.idle)
+	}
+	d.mu.Unlock()
+}
+
+// ListenAndServe runs srv until the process receives SIGTERM or an
+// interrupt. It then drains s, which first makes readiness fail as
+// described in Drain, and shuts srv down, allowing up to timeout for
+// both. srv.Handler defaults to s, and may be another handler wrapping
+// it, such as an AccessLogger.
+func (s *Service) ListenAndServe(srv *http.Server, timeout time.Duration) error {
+	if srv.Handler == nil {
+		srv.Handler = s
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-sigs:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := s.Drain(ctx)
+	if shutdownErr := srv.Shutdown(ctx); err == nil {
+		err = shutdownErr
+	}
+	if serveErr := <-errc; err == nil && serveErr != http.ErrServerClosed {
+		err = serveErr
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+package siesta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	s := NewService("drain")
+	s.Route(http.MethodGet, "/slow", "Slow route", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	var hookRan bool
+	s.OnShutdown(func(ctx context.Context) error {
+		if n := s.InFlight(); n != 0 {
+			t.Errorf("expected no requests in flight got %d", n)
+		}
+		hookRan = true
+		return nil
+	})
+
+	slow := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		s.ServeHTTP(slow, httptest.NewRequest(http.MethodGet, "/drain/slow", nil))
+		close(served)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Drain(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/drain/slow", nil))
+	if want, got := http.StatusServiceUnavailable, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+	if want, got := "close", w.Header().Get("Connection"); want != got {
+		t.Errorf("expected Connection %q got %q", want, got)
+	}
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- s.Drain(context.Background())
+	}()
+	close(release)
+
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+	<-served
+	if want, got := http.StatusAccepted, slow.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+	if !hookRan {
+		t.Error("expected the shutdown hook to run")
+	}
+}
+
+func TestDrainHealth(t *testing.T) {
+	s := NewService("drain-health")
+	s.SetDrainDelay(50 * time.Millisecond)
+	NewHealth().AddRoutes(s)
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- s.Drain(context.Background())
+	}()
+
+	// Readiness fails while requests are still served.
+	var ready, live int
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/drain-health/readyz", nil))
+		ready = w.Code
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/drain-health/healthz", nil))
+		live = w.Code
+		if ready == http.StatusServiceUnavailable {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if want := http.StatusServiceUnavailable; ready != want {
+		t.Errorf("expected readiness status %d got %d", want, ready)
+	}
+	if want := http.StatusOK; live != want {
+		t.Errorf("expected liveness status %d during the delay, got %d", want, live)
+	}
+
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/drain-health/healthz", nil))
+	if want, got := "close", w.Header().Get("Connection"); want != got {
+		t.Errorf("expected Connection %q after draining, got %q", want, got)
+	}
+}
+
+func TestDrainAbandonedHandler(t *testing.T) {
+	release := make(chan struct{})
+	exited := make(chan struct{})
+
+	s := NewService("drain-abandoned")
+	s.SetTimeout(time.Millisecond)
+	s.Route(http.MethodGet, "/slow", "Slow route", func(w http.ResponseWriter, r *http.Request) {
+		defer close(exited)
+		<-release
+	})
+
+	var hookRan bool
+	s.OnShutdown(func(ctx context.Context) error {
+		select {
+		case <-exited:
+		default:
+			t.Error("expected the abandoned handler to have returned")
+		}
+		hookRan = true
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/drain-abandoned/slow", nil))
+	if want, got := http.StatusServiceUnavailable, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+	if n := s.InFlight(); n != 1 {
+		t.Errorf("expected the abandoned handler in flight got %d", n)
+	}
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- s.Drain(context.Background())
+	}()
+	select {
+	case err := <-drained:
+		t.Fatalf("expected Drain to wait, got %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+	if !hookRan {
+		t.Error("expected the shutdown hook to run")
+	}
+}
@@ -0,0 +1,47 @@
+package siesta
+
+import (
+	"net/http"
+)
+
+// StatusError is an error detected by a Service while serving a request,
+// along with the HTTP status code it should be answered with.
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// SetErrorHandler sets the handler for errors detected by the Service
+// itself, such as request bodies over the size limit. It runs in place
+// of the main handler, and the *StatusError is available in the Context
+// under ErrorContextKey. It accepts the same function signatures that
+// Route does with the addition of `nil`, which restores the default
+// handler that responds with the status code and its text.
+func (s *Service) SetErrorHandler(f interface{}) {
+	if f == nil {
+		s.errorHandler = nil
+		return
+	}
+
+	s.errorHandler = ToContextHandler(f)
+}
+
+// serveError runs the error handler for err.
+func (s *Service) serveError(c Context, w http.ResponseWriter, r *http.Request, err *StatusError) {
+	c.Set(ErrorContextKey, err)
+
+	if s.errorHandler != nil {
+		s.errorHandler(c, w, r, func() {})
+		return
+	}
+	http.Error(w, http.StatusText(err.Status), err.Status)
+}
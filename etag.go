@@ -0,0 +1,220 @@
+package siesta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// validatorsContextKey is a special context key set when a handler
+// provides its own validators through SetValidators.
+const validatorsContextKey = nullByteStr + "validators"
+
+// ETagger adds entity tags to successful GET responses and answers
+// conditional requests with 304 Not Modified. Its Handle method should
+// be added to the "pre" chain. If a Compressor is also used, the ETagger
+// should be added first so that tags are computed on the bytes that are
+// actually sent.
+//
+// Responses are buffered to compute their tag. Responses that are flushed,
+// exceed the maximum size or already have an ETag header are sent as they
+// are written. Handlers that can tell whether a resource changed without
+// generating it should call SetValidators.
+type ETagger struct {
+	weak    bool
+	maxSize int
+}
+
+// NewETagger returns an ETagger that generates strong tags
+// for responses of up to 1 MiB.
+func NewETagger() *ETagger {
+	return &ETagger{
+		maxSize: 1 << 20,
+	}
+}
+
+// SetWeak sets whether generated tags are weak.
+func (e *ETagger) SetWeak(weak bool) {
+	e.weak = weak
+}
+
+// SetMaxSize sets the size of the largest response to buffer.
+func (e *ETagger) SetMaxSize(n int) {
+	e.maxSize = n
+}
+
+// Handle is a "pre" handler that sets up the tagging of the response.
+// It has no effect unless w was provided by a Service.
+func (e *ETagger) Handle(c Context, w http.ResponseWriter, r *http.Request) {
+	rw, ok := w.(*responseWriter)
+	if !ok || rw.Status() != 0 || r.Method != http.MethodGet {
+		return
+	}
+
+	ew := &etagWriter{
+		ResponseWriter: rw.ResponseWriter,
+		etagger:        e,
+		c:              c,
+		r:              r,
+	}
+	rw.filter(ew, ew.close)
+}
+
+// SetValidators sets the ETag and Last-Modified headers of the response
+// to etag and lastModified, either of which may be empty. It returns true
+// if the request's preconditions show the client has the current version
+// of the resource, in which case a 304 Not Modified response has been
+// written and the handler should return without writing a body.
+func SetValidators(c Context, w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	c.Set(validatorsContextKey, true)
+
+	h := w.Header()
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || !notModified(r, h) {
+		return false
+	}
+	writeNotModified(w)
+	return true
+}
+
+// notModified reports whether the conditional headers of r match
+// the validators in the response header h.
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakMatch(candidate, etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	lm := h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// weakMatch compares two entity tags ignoring their weakness.
+func weakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// etagWriter buffers a response to compute its entity tag.
+type etagWriter struct {
+	http.ResponseWriter
+
+	etagger *ETagger
+	c       Context
+	r       *http.Request
+
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.passthrough || code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.passthrough &&
+		(w.c.Get(validatorsContextKey) != nil || w.Header().Get("ETag") != "" ||
+			w.buf.Len()+len(b) > w.etagger.maxSize) {
+		if err := w.startPassthrough(); err != nil {
+			return 0, err
+		}
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *etagWriter) Flush() {
+	if !w.passthrough {
+		w.startPassthrough()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// startPassthrough sends the header and whatever has been buffered.
+func (w *etagWriter) startPassthrough() error {
+	w.passthrough = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *etagWriter) close() {
+	if w.passthrough {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+	if w.status == http.StatusOK {
+		if h.Get("ETag") == "" {
+			sum := sha256.Sum256(w.buf.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			if w.etagger.weak {
+				etag = "W/" + etag
+			}
+			h.Set("ETag", etag)
+		}
+		if notModified(w.r, h) {
+			writeNotModified(w.ResponseWriter)
+			return
+		}
+	}
+
+	w.startPassthrough()
+}
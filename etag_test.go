@@ -0,0 +1,90 @@
+package siesta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETagger(t *testing.T) {
+	s := NewService("tagged")
+	s.AddPre(NewETagger().Handle)
+	s.Route(http.MethodGet, "/doc", "Gets a document", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tagged/doc", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected a tagged 200 response, got %d with ETag %q", w.Code, etag)
+	}
+	if want, got := "hello", w.Body.String(); want != got {
+		t.Errorf("expected body %q got %q", want, got)
+	}
+
+	for inm, want := range map[string]int{
+		etag:               http.StatusNotModified,
+		"W/" + etag:        http.StatusNotModified,
+		`"other", ` + etag: http.StatusNotModified,
+		"*":                http.StatusNotModified,
+		`"other"`:          http.StatusOK,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/tagged/doc", nil)
+		r.Header.Set("If-None-Match", inm)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+
+		if got := w.Code; want != got {
+			t.Errorf("%s: expected status %d got %d", inm, want, got)
+		}
+		if want == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%s: expected no body got %q", inm, w.Body.String())
+		}
+	}
+}
+
+func TestSetValidators(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	generated := 0
+
+	s := NewService("validated")
+	s.AddPre(NewETagger().Handle)
+	s.Route(http.MethodGet, "/report", "Gets a report", func(c Context, w http.ResponseWriter, r *http.Request) {
+		if SetValidators(c, w, r, `"v1"`, modified) {
+			return
+		}
+		generated++
+		w.Write([]byte("report"))
+	})
+
+	for _, test := range []struct {
+		header, value string
+		status        int
+	}{
+		{"", "", http.StatusOK},
+		{"If-None-Match", `"v1"`, http.StatusNotModified},
+		{"If-None-Match", `"v0"`, http.StatusOK},
+		{"If-Modified-Since", modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/validated/report", nil)
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+
+		if want, got := test.status, w.Code; want != got {
+			t.Errorf("%s %s: expected status %d got %d", test.header, test.value, want, got)
+		}
+		if want, got := `"v1"`, w.Header().Get("ETag"); want != got {
+			t.Errorf("%s %s: expected ETag %s got %s", test.header, test.value, want, got)
+		}
+	}
+	if want, got := 3, generated; want != got {
+		t.Errorf("expected %d generated reports got %d", want, got)
+	}
+}
@@ -5,16 +5,18 @@ import (
 
 	"log"
 	"net/http"
+	"os"
 )
 
 func main() {
 	// Create a new service rooted at /.
 	service := siesta.NewService("/")
 
-	// requestIdentifier assigns an ID to every request
-	// and adds it to the context for that request.
-	// This is useful for logging.
-	service.AddPre(requestIdentifier)
+	// The request identifier assigns an ID to every request,
+	// adds it to the context for that request and sends it back
+	// in the X-Request-ID header. The access logger includes it
+	// in every entry.
+	service.AddPre(siesta.NewRequestIdentifier().Handle)
 
 	// Add access to the state via the context in every handler.
 	service.AddPre(func(c siesta.Context, w http.ResponseWriter, r *http.Request) {
@@ -39,6 +41,9 @@ func main() {
 	service.Route("GET", "/resources/:resourceID", "Retrieves a resource",
 		getResource)
 
+	// Log every request in the Combined Log Format.
+	accessLogger := siesta.NewAccessLogger(os.Stdout, siesta.CombinedLogFormat)
+
 	log.Println("Listening on :8080")
-	panic(http.ListenAndServe(":8080", service))
+	panic(http.ListenAndServe(":8080", accessLogger.Wrap(service)))
 }
@@ -4,9 +4,7 @@ import (
 	"github.com/VividCortex/siesta"
 
 	"encoding/json"
-	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
 )
 
@@ -16,21 +14,13 @@ type apiResponse struct {
 	Error string      `json:"error,omitempty"`
 }
 
-// requestIdentifier generates a request ID and sets the "request-id"
-// key in the context. It also logs the request ID and the requested URL.
-func requestIdentifier(c siesta.Context, w http.ResponseWriter, r *http.Request) {
-	requestID := fmt.Sprintf("%x", rand.Int())
-	c.Set("request-id", requestID)
-	log.Printf("[Req %s] %s %s", requestID, r.Method, r.URL)
-}
-
 // authenticator reads the username from the HTTP basic authentication header
 // and validates the token. It sets the "user" key in the context to the
 // user associated with the token.
 func authenticator(c siesta.Context, w http.ResponseWriter, r *http.Request,
 	quit func()) {
 	// Context variables
-	requestID := c.Get("request-id").(string)
+	requestID := c.Get(siesta.RequestIDContextKey).(string)
 	db := c.Get("db").(*DB)
 
 	// Check for a token in the HTTP basic authentication username field.
@@ -81,11 +71,6 @@ func responseGenerator(c siesta.Context, w http.ResponseWriter, r *http.Request)
 // writes a JSON-encoded response to the client.
 func responseWriter(c siesta.Context, w http.ResponseWriter, r *http.Request,
 	quit func()) {
-	// Set the request ID header.
-	if requestID := c.Get("request-id"); requestID != nil {
-		w.Header().Set("X-Request-ID", requestID.(string))
-	}
-
 	// Set the content type.
 	w.Header().Set("Content-Type", "application/json")
 
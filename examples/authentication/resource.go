@@ -10,13 +10,14 @@ import (
 // getResource is the function that handles the GET /resources/:resourceID route.
 func getResource(c siesta.Context, w http.ResponseWriter, r *http.Request) {
 	// Context variables
-	requestID := c.Get("request-id").(string)
+	requestID := c.Get(siesta.RequestIDContextKey).(string)
 	db := c.Get("db").(*DB)
 	user := c.Get("user").(string)
 
 	// Check parameters
 	var params siesta.Params
-	resourceID := params.Int("resourceID", -1, "Resource identifier")
+	resourceID := params.Int("resourceID", 0, "Resource identifier")
+	params.Required("resourceID")
 	err := params.Parse(r.Form)
 	if err != nil {
 		log.Printf("[Req %s] %v", requestID, err)
@@ -25,13 +26,6 @@ func getResource(c siesta.Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Make sure we have a valid resource ID.
-	if *resourceID == -1 {
-		c.Set("error", "invalid or missing resource ID")
-		c.Set("status-code", http.StatusBadRequest)
-		return
-	}
-
 	resource, err := db.resource(user, *resourceID)
 	if err != nil {
 		c.Set("status-code", http.StatusNotFound)
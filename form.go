@@ -0,0 +1,134 @@
+package siesta
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// defaultMaxMemory is the amount of a multipart form kept in memory,
+// as in net/http. The rest is stored in temporary files.
+const defaultMaxMemory = 32 << 20
+
+// FormPolicy is how a Service parses the form of a request
+// before running its chains.
+type FormPolicy int
+
+const (
+	// ParseURLEncoded parses the query string and URL-encoded bodies,
+	// as http.Request.ParseForm does. This is the default.
+	ParseURLEncoded FormPolicy = iota
+	// ParseQuery parses the query string only, leaving the body unread.
+	ParseQuery
+	// ParseMultipart parses multipart bodies in addition to the query
+	// string and URL-encoded bodies.
+	ParseMultipart
+	// ParseLazily leaves the form unparsed until the main handler calls
+	// Params.ParseRequest. Route parameters are not available in
+	// http.Request.Form before that.
+	ParseLazily
+)
+
+// routeParamsKey is the request context key
+// holding the route parameters of a request.
+type routeParamsKey struct{}
+
+// queryOnlyKey is the request context key marking requests whose
+// body is left unread by the ParseQuery policy.
+type queryOnlyKey struct{}
+
+// SetFormPolicy sets how request forms are parsed. Parsing errors are
+// answered with 400 Bad Request through the error handler, or with 413
+// Request Entity Too Large for bodies over the size limits. Routes can
+// override the policy with the FormParsing option.
+func (s *Service) SetFormPolicy(p FormPolicy) {
+	s.formPolicy = p
+}
+
+// FormParsing is a route option that overrides the form policy of the Service.
+func FormParsing(p FormPolicy) RouteOption {
+	return func(o *routeOptions) {
+		o.formPolicy = &p
+	}
+}
+
+// formPolicyFor returns the form policy of the route with opts.
+func (s *Service) formPolicyFor(opts *routeOptions) FormPolicy {
+	if opts != nil && opts.formPolicy != nil {
+		return *opts.formPolicy
+	}
+	return s.formPolicy
+}
+
+// parseForm parses the form of r according to policy p.
+func parseForm(r *http.Request, p FormPolicy) *StatusError {
+	var err error
+	switch p {
+	case ParseQuery:
+		r.Form, err = url.ParseQuery(r.URL.RawQuery)
+		// Keep ParseForm from reading the body later on.
+		r.PostForm = url.Values{}
+	case ParseMultipart:
+		err = r.ParseMultipartForm(defaultMaxMemory)
+		if err == http.ErrNotMultipart {
+			err = nil
+		}
+	case ParseLazily:
+		return nil
+	default:
+		err = r.ParseForm()
+	}
+
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrRequestBodyTooLarge) || errors.Is(err, ErrDecompressedBodyTooLarge) {
+		return &StatusError{Status: http.StatusRequestEntityTooLarge, Err: err}
+	}
+	return &StatusError{Status: http.StatusBadRequest, Err: err}
+}
+
+// setQueryOnly returns r marked as parsed by the ParseQuery policy, if p
+// is that policy, so that the body is not read as a form later on.
+func setQueryOnly(r *http.Request, p FormPolicy) *http.Request {
+	if p != ParseQuery {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), queryOnlyKey{}, true))
+}
+
+// isQueryOnly reports whether r was parsed by the ParseQuery policy.
+func isQueryOnly(r *http.Request) bool {
+	queryOnly, _ := r.Context().Value(queryOnlyKey{}).(bool)
+	return queryOnly
+}
+
+// setRouteParams makes the route parameters available in the form of r,
+// unless it has not been parsed yet. It returns r with the parameters
+// stored in its context, for parseLazyForm and FromPath parameters.
+func setRouteParams(r *http.Request, params routeParams) *http.Request {
+	if len(params) == 0 {
+		return r
+	}
+	if r.Form != nil {
+		for _, p := range params {
+			r.Form.Set(p.Key, p.Value)
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), routeParamsKey{}, params))
+}
+
+// parseLazyForm parses the form of a request left unparsed
+// by the Service, including multipart bodies.
+func parseLazyForm(r *http.Request) error {
+	if err := parseForm(r, ParseMultipart); err != nil {
+		return err
+	}
+	if params, ok := r.Context().Value(routeParamsKey{}).(routeParams); ok {
+		for _, p := range params {
+			r.Form.Set(p.Key, p.Value)
+		}
+	}
+	return nil
+}
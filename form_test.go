@@ -0,0 +1,63 @@
+package siesta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFormParseError(t *testing.T) {
+	var got error
+	s := NewService("bad-forms")
+	s.SetErrorHandler(func(c Context, w http.ResponseWriter, r *http.Request) {
+		got, _ = c.Get(ErrorContextKey).(*StatusError)
+		http.Error(w, "bad form", http.StatusBadRequest)
+	})
+	s.Route(http.MethodGet, "/", "Reads the query", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected the main handler to be skipped")
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bad-forms?a=%zz", nil))
+
+	if want, got := http.StatusBadRequest, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+	if se, ok := got.(*StatusError); !ok || se.Status != http.StatusBadRequest {
+		t.Errorf("unexpected error %v", got)
+	}
+}
+
+func TestFormPolicies(t *testing.T) {
+	s := NewService("form-policies")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var params Params
+		id := params.String("id", "", "ID")
+		q := params.String("q", "", "Query")
+		b := params.String("b", "", "Body")
+		if err := params.ParseRequest(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(*id + "," + *q + "," + *b))
+	}
+	s.Route(http.MethodPost, "/default/:id", "Default policy", handler)
+	s.Route(http.MethodPost, "/query/:id", "Query only", handler, FormParsing(ParseQuery))
+	s.Route(http.MethodPost, "/lazy/:id", "Lazy parsing", handler, FormParsing(ParseLazily))
+
+	for path, want := range map[string]string{
+		"/form-policies/default/1?q=x": "1,x,y",
+		"/form-policies/query/1?q=x":   "1,x,",
+		"/form-policies/lazy/1?q=x":    "1,x,y",
+	} {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader("b=y"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+
+		if got := w.Body.String(); want != got {
+			t.Errorf("%s: expected %q got %q", path, want, got)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package siesta
 import (
 	"errors"
 	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
 )
 
 var ErrUnsupportedHandler = errors.New("siesta: unsupported handler")
@@ -51,6 +54,24 @@ func ToContextHandler(f interface{}) ContextHandler {
 	}
 }
 
+// handlerName returns the name of the function f, without
+// its import path, as in "siesta.(*CORS).Handle".
+func handlerName(f interface{}) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func {
+		return reflect.TypeOf(f).String()
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
 // Compose composes multiple ContextHandlers into a single ContextHandler.
 func Compose(stack ...interface{}) ContextHandler {
 	contextStack := make([]ContextHandler, 0, len(stack))
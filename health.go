@@ -0,0 +1,193 @@
+package siesta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrShuttingDown is the error reported by the readiness endpoint of a
+// Health once it is shutting down, and by a Service once it is draining.
+var ErrShuttingDown = errors.New("siesta: shutting down")
+
+// HealthCheck checks a component. It should return
+// once ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+// Health serves health checks. Components register named checks, and
+// the liveness and readiness endpoints run them concurrently, reporting
+// the status of each check and the aggregate in JSON:
+//
+//	{"status":"fail","checks":{"db":{"status":"fail","error":"timeout"}}}
+//
+// The status code is 200 if every check passed and
+// 503 Service Unavailable otherwise.
+type Health struct {
+	mu        sync.RWMutex
+	liveness  []namedCheck
+	readiness []namedCheck
+
+	shuttingDown int32
+}
+
+type namedCheck struct {
+	name    string
+	timeout time.Duration
+	check   HealthCheck
+}
+
+// checkResult is the JSON result of a check.
+type checkResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+type healthReport struct {
+	Status string                  `json:"status"`
+	Error  string                  `json:"error,omitempty"`
+	Checks map[string]*checkResult `json:"checks"`
+}
+
+// NewHealth returns a Health without checks.
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// AddLivenessCheck adds a check of whether the process is working at
+// all. Liveness checks also count towards readiness. A check that runs
+// for longer than timeout fails; zero means no timeout.
+func (h *Health) AddLivenessCheck(name string, timeout time.Duration, check HealthCheck) {
+	h.mu.Lock()
+	h.liveness = append(h.liveness, namedCheck{name, timeout, check})
+	h.mu.Unlock()
+}
+
+// AddReadinessCheck adds a check of whether the process can serve
+// requests, such as a database ping. A check that runs for longer
+// than timeout fails; zero means no timeout.
+func (h *Health) AddReadinessCheck(name string, timeout time.Duration, check HealthCheck) {
+	h.mu.Lock()
+	h.readiness = append(h.readiness, namedCheck{name, timeout, check})
+	h.mu.Unlock()
+}
+
+// Shutdown makes readiness fail from now on, so that load balancers
+// stop sending requests. Service.Drain calls it for the Health endpoints
+// added to the Service; Health endpoints served otherwise must be shut
+// down before draining.
+func (h *Health) Shutdown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+// AddRoutes adds the GET /healthz (liveness) and GET /readyz (readiness)
+// routes to s. Service.Drain shuts h down before draining s.
+func (h *Health) AddRoutes(s *Service) {
+	s.addHealth(h)
+	s.Route(http.MethodGet, "/healthz", "Reports whether the service is alive", h.ServeLiveness)
+	s.Route(http.MethodGet, "/readyz", "Reports whether the service is ready to serve requests", h.ServeReadiness)
+}
+
+// ServeLiveness runs the liveness checks and writes the report.
+func (h *Health) ServeLiveness(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	checks := h.liveness
+	h.mu.RUnlock()
+
+	h.serve(w, r, checks, nil)
+}
+
+// ServeReadiness runs the liveness and readiness checks and writes the
+// report. It fails without running them when shutting down.
+func (h *Health) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&h.shuttingDown) == 1 {
+		h.serve(w, r, nil, ErrShuttingDown)
+		return
+	}
+
+	h.mu.RLock()
+	checks := append(h.liveness[:len(h.liveness):len(h.liveness)], h.readiness...)
+	h.mu.RUnlock()
+
+	h.serve(w, r, checks, nil)
+}
+
+func (h *Health) serve(w http.ResponseWriter, r *http.Request, checks []namedCheck, err error) {
+	report := &healthReport{
+		Status: "ok",
+		Checks: runChecks(r.Context(), checks),
+	}
+	if err != nil {
+		report.Status = "fail"
+		report.Error = err.Error()
+	}
+	for _, result := range report.Checks {
+		if result.Status != "ok" {
+			report.Status = "fail"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// runChecks runs checks concurrently.
+func runChecks(ctx context.Context, checks []namedCheck) map[string]*checkResult {
+	results := make(map[string]*checkResult, len(checks))
+	var wg sync.WaitGroup
+	for _, nc := range checks {
+		result := &checkResult{}
+		results[nc.name] = result
+
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := runCheck(ctx, nc)
+			result.DurationMS = float64(time.Since(start)) / float64(time.Millisecond)
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			} else {
+				result.Status = "ok"
+			}
+		}(nc)
+	}
+	wg.Wait()
+	return results
+}
+
+// runCheck runs the check of nc, giving up once its timeout expires.
+func runCheck(ctx context.Context, nc namedCheck) error {
+	if nc.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, nc.timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- nc.check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+package siesta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	h := NewHealth()
+	h.AddLivenessCheck("loop", 0, func(ctx context.Context) error {
+		return nil
+	})
+	h.AddReadinessCheck("db", 0, func(ctx context.Context) error {
+		return nil
+	})
+	h.AddReadinessCheck("cache", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	s := NewService("health")
+	h.AddRoutes(s)
+
+	check := func(path string, status int) map[string]interface{} {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != status {
+			t.Errorf("%s: expected status %d got %d", path, status, w.Code)
+		}
+		var report map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	report := check("/health/healthz", http.StatusOK)
+	if checks := report["checks"].(map[string]interface{}); len(checks) != 1 {
+		t.Errorf("expected 1 liveness check got %v", checks)
+	}
+
+	report = check("/health/readyz", http.StatusServiceUnavailable)
+	checks := report["checks"].(map[string]interface{})
+	if want, got := "context deadline exceeded", checks["cache"].(map[string]interface{})["error"]; want != got {
+		t.Errorf("expected cache error %q got %v", want, got)
+	}
+	if want, got := "ok", checks["db"].(map[string]interface{})["status"]; want != got {
+		t.Errorf("expected db status %q got %v", want, got)
+	}
+
+	h.Shutdown()
+	report = check("/health/readyz", http.StatusServiceUnavailable)
+	if want, got := ErrShuttingDown.Error(), report["error"]; want != got {
+		t.Errorf("expected error %q got %v", want, got)
+	}
+	check("/health/healthz", http.StatusOK)
+}
@@ -0,0 +1,133 @@
+package siesta
+
+import (
+	"net/http"
+)
+
+// Hook is a function called by a Service at some point
+// in the lifecycle of a request.
+type Hook func(c Context, r *http.Request)
+
+// hooks are the lifecycle hooks of a Service.
+type hooks struct {
+	requestStart    []Hook
+	routeMatched    []Hook
+	notFound        []Hook
+	quit            []func(c Context, r *http.Request, chain, handler string)
+	panics          []func(c Context, r *http.Request, value interface{})
+	responseHeaders []func(c Context, r *http.Request, status int, header http.Header)
+	requestEnd      []func(c Context, r *http.Request, status int)
+}
+
+// OnRequestStart adds a hook that is called when s starts serving a
+// request, before the "pre" chain.
+func (s *Service) OnRequestStart(f Hook) {
+	s.hooks.requestStart = append(s.hooks.requestStart, f)
+}
+
+// OnRouteMatched adds a hook that is called when the request matches a
+// route, before the main handler. The pattern of the route is available
+// in the Context under RouteContextKey.
+func (s *Service) OnRouteMatched(f Hook) {
+	s.hooks.routeMatched = append(s.hooks.routeMatched, f)
+}
+
+// OnNotFound adds a hook that is called when the
+// request matches no route, before the NotFound handler.
+func (s *Service) OnNotFound(f Hook) {
+	s.hooks.notFound = append(s.hooks.notFound, f)
+}
+
+// OnQuit adds a hook that is called when a handler calls its quit
+// function. chain is "pre", "main" or "post", and handler is the name
+// of the handler's function, as in "siesta.(*CORS).Handle".
+func (s *Service) OnQuit(f func(c Context, r *http.Request, chain, handler string)) {
+	s.hooks.quit = append(s.hooks.quit, f)
+}
+
+// OnPanic adds a hook that is called with the recovered value when a
+// handler panics, before the post execution function. The panic goes on
+// once the hooks return.
+func (s *Service) OnPanic(f func(c Context, r *http.Request, value interface{})) {
+	s.hooks.panics = append(s.hooks.panics, f)
+}
+
+// OnResponseHeaders adds a hook that is called right before the
+// response header is written, while it can still be modified. status is
+// the status code written by the handlers, which filters such as an
+// ETagger may still change.
+func (s *Service) OnResponseHeaders(f func(c Context, r *http.Request, status int, header http.Header)) {
+	s.hooks.responseHeaders = append(s.hooks.responseHeaders, f)
+}
+
+// OnRequestEnd adds a hook that is called once s is done with the
+// request, even if it panicked. status is the status code sent to the
+// client, or 500 if the request panicked before writing it.
+func (s *Service) OnRequestEnd(f func(c Context, r *http.Request, status int)) {
+	s.hooks.requestEnd = append(s.hooks.requestEnd, f)
+}
+
+// runHooks calls each of hs.
+func runHooks(hs []Hook, c Context, r *http.Request) {
+	for _, f := range hs {
+		f(c, r)
+	}
+}
+
+// startHooks runs the request start hooks and sets up the response header hooks.
+func (s *Service) startHooks(c Context, rw *responseWriter, r *http.Request) {
+	runHooks(s.hooks.requestStart, c, r)
+
+	if len(s.hooks.responseHeaders) > 0 {
+		rw.beforeHeader(func(status int) {
+			for _, f := range s.hooks.responseHeaders {
+				f(c, r, status, rw.Header())
+			}
+		})
+	}
+}
+
+// endHooks runs the panic and request end hooks.
+func (s *Service) endHooks(c Context, r *http.Request, status int, panicValue interface{}) {
+	if panicValue != nil {
+		for _, f := range s.hooks.panics {
+			f(c, r, panicValue)
+		}
+	}
+	for _, f := range s.hooks.requestEnd {
+		f(c, r, status)
+	}
+}
+
+// quitHooked returns h wrapped to run the quit hooks
+// when it quits, if there are any.
+func (s *Service) quitHooked(chain, name string, h ContextHandler) ContextHandler {
+	if len(s.hooks.quit) == 0 {
+		return h
+	}
+	return func(c Context, w http.ResponseWriter, r *http.Request, quit func()) {
+		h(c, w, r, func() {
+			for _, f := range s.hooks.quit {
+				f(c, r, chain, name)
+			}
+			quit()
+		})
+	}
+}
+
+// responseStatus returns the status code of the response. sent records
+// what the filters of rw, such as an ETagger, actually sent, and rw what
+// the handlers wrote, in case the filters were not finished. It fills in
+// the status code net/http sends if none was written.
+func responseStatus(sent, rw *responseWriter, panicked bool) int {
+	if status := sent.Status(); status != 0 {
+		return status
+	}
+	if status := rw.Status(); status != 0 {
+		return status
+	}
+	if panicked {
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,130 @@
+package siesta
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var events []string
+	record := func(event string) Hook {
+		return func(c Context, r *http.Request) {
+			events = append(events, event)
+		}
+	}
+
+	s := NewService("hooks")
+	s.OnRequestStart(record("start"))
+	s.OnRouteMatched(func(c Context, r *http.Request) {
+		events = append(events, "matched "+c.Get(RouteContextKey).(string))
+	})
+	s.OnNotFound(record("not found"))
+	s.OnQuit(func(c Context, r *http.Request, chain, handler string) {
+		events = append(events, "quit "+chain+" "+handler)
+	})
+	s.OnPanic(func(c Context, r *http.Request, value interface{}) {
+		events = append(events, fmt.Sprint("panic ", value))
+	})
+	s.OnResponseHeaders(func(c Context, r *http.Request, status int, header http.Header) {
+		header.Set("X-Audited", "true")
+		events = append(events, fmt.Sprint("headers ", status))
+	})
+	s.OnRequestEnd(func(c Context, r *http.Request, status int) {
+		events = append(events, fmt.Sprint("end ", status))
+	})
+
+	s.AddPre(func(w http.ResponseWriter, r *http.Request, quit func()) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			quit()
+		}
+	})
+	s.Route(http.MethodGet, "/items/:id", "Gets an item", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	s.Route(http.MethodGet, "/panic", "Panics", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	serve := func(path string, authorized bool) http.Header {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorized {
+			r.Header.Set("Authorization", "yes")
+		}
+		w := httptest.NewRecorder()
+		func() {
+			defer func() { recover() }()
+			s.ServeHTTP(w, r)
+		}()
+		return w.Header()
+	}
+
+	for _, tc := range []struct {
+		path       string
+		authorized bool
+		events     []string
+	}{
+		{"/hooks/items/1", true, []string{"start", "matched /hooks/items/:id", "headers 201", "end 201"}},
+		{"/hooks/items/1", false, []string{"start", "headers 401", "quit pre siesta.TestHooks.func7", "end 401"}},
+		{"/hooks/nowhere", true, []string{"start", "not found", "headers 404", "end 404"}},
+		{"/hooks/panic", true, []string{"start", "matched /hooks/panic", "panic boom", "end 500"}},
+	} {
+		events = nil
+		h := serve(tc.path, tc.authorized)
+		if !reflect.DeepEqual(events, tc.events) {
+			t.Errorf("%s: expected events %q got %q", tc.path, tc.events, events)
+		}
+		if tc.events[2] != "panic boom" && h.Get("X-Audited") != "true" {
+			t.Errorf("%s: expected the header set by the hook", tc.path)
+		}
+	}
+}
+
+func TestHooksFilteredStatus(t *testing.T) {
+	var ended, headers int
+	exporter := &MemorySpanExporter{}
+
+	s := NewService("hooks-filtered")
+	s.SetTracer(NewTracer(exporter))
+	s.AddPre(NewETagger().Handle)
+	s.OnResponseHeaders(func(c Context, r *http.Request, status int, header http.Header) {
+		headers = status
+	})
+	s.OnRequestEnd(func(c Context, r *http.Request, status int) {
+		ended = status
+	})
+	s.Route(http.MethodGet, "/doc", "Gets a document", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hooks-filtered/doc", nil))
+
+	r := httptest.NewRequest(http.MethodGet, "/hooks-filtered/doc", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if want, got := http.StatusNotModified, w.Code; want != got {
+		t.Fatalf("expected status %d got %d", want, got)
+	}
+	if want, got := http.StatusNotModified, ended; want != got {
+		t.Errorf("expected the end hook to get status %d, got %d", want, got)
+	}
+	if want, got := http.StatusOK, headers; want != got {
+		t.Errorf("expected the header hook to get the status of the handler %d, got %d", want, got)
+	}
+
+	var status string
+	for _, span := range exporter.Spans() {
+		if code, ok := span.Attributes["http.status_code"]; ok {
+			status = code
+		}
+	}
+	if want, got := "304", status; want != got {
+		t.Errorf("expected the trace to record status %s, got %s", want, got)
+	}
+}
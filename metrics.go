@@ -0,0 +1,307 @@
+package siesta
+
+import (
+	"bufio"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of the
+// request duration histogram of a Metrics.
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the upper bounds, in bytes, of the
+// response size histogram of a Metrics.
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+
+// Metrics records the requests served by a Service and exposes them in
+// the Prometheus text format. Requests are labeled by verb, route pattern
+// and status class ("2xx", "4xx", ...). Requests that match no route have
+// an empty route label, and those with an unknown method have the "other"
+// method label, so the number of series stays bounded.
+//
+// The following metrics are recorded:
+//
+//	siesta_requests_in_flight         gauge, by method and route
+//	siesta_requests_total             counter
+//	siesta_request_duration_seconds   histogram
+//	siesta_response_size_bytes        histogram
+//
+// Requests are recorded by the handler returned by Wrap, and the
+// metrics are served by ServeHTTP, which can be added as a route:
+//
+//	m := siesta.NewMetrics(s)
+//	s.Route("GET", "/metrics", "Serves metrics", m.ServeHTTP)
+//	http.ListenAndServe(":8080", m.Wrap(s))
+type Metrics struct {
+	service *Service
+
+	durationBuckets []float64
+	sizeBuckets     []float64
+
+	mu       sync.Mutex
+	inFlight map[metricKey]int64
+	requests map[metricKey]*requestMetrics
+}
+
+// metricKey identifies a series. status is empty for in-flight requests.
+type metricKey struct {
+	method string
+	route  string
+	status string
+}
+
+type requestMetrics struct {
+	count    uint64
+	duration histogram
+	size     histogram
+}
+
+// histogram counts observations in buckets. counts[i] is the number of
+// observations not greater than the ith bound, excluding earlier buckets.
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+func (h *histogram) observe(bounds []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(bounds))
+	}
+	h.sum += v
+	if i := sort.SearchFloat64s(bounds, v); i < len(bounds) {
+		h.counts[i]++
+	}
+}
+
+// NewMetrics returns a Metrics for the routes of s, with the default buckets.
+func NewMetrics(s *Service) *Metrics {
+	return &Metrics{
+		service:         s,
+		durationBuckets: DefaultDurationBuckets,
+		sizeBuckets:     DefaultSizeBuckets,
+		inFlight:        map[metricKey]int64{},
+		requests:        map[metricKey]*requestMetrics{},
+	}
+}
+
+// SetDurationBuckets sets the upper bounds, in seconds, of the request
+// duration histogram. It must be called before any request is recorded.
+func (m *Metrics) SetDurationBuckets(bounds ...float64) {
+	m.durationBuckets = sortedBounds(bounds)
+}
+
+// SetSizeBuckets sets the upper bounds, in bytes, of the response size
+// histogram. It must be called before any request is recorded.
+func (m *Metrics) SetSizeBuckets(bounds ...float64) {
+	m.sizeBuckets = sortedBounds(bounds)
+}
+
+func sortedBounds(bounds []float64) []float64 {
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+	return bounds
+}
+
+// Wrap returns an http.Handler that serves requests with h and
+// records each of them. h is usually the Service of m, or another
+// handler wrapping it, such as an AccessLogger.
+func (m *Metrics) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := newResponseWriter(w)
+		key := metricKey{
+			method: m.methodLabel(r.Method),
+			route:  m.service.pattern(r.Method, r.URL.Path),
+		}
+
+		m.mu.Lock()
+		m.inFlight[key]++
+		m.mu.Unlock()
+
+		defer func() {
+			p := recover()
+
+			status := rw.Status()
+			if status == 0 {
+				if p != nil {
+					status = http.StatusInternalServerError
+				} else {
+					status = http.StatusOK
+				}
+			}
+			m.record(key, status, time.Since(start), rw.Size())
+
+			if p != nil {
+				panic(p)
+			}
+		}()
+
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// standardMethods are the methods defined by RFC 7231 and RFC 5789.
+var standardMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// methodLabel returns the method label of requests with the given method.
+// Methods that are neither standard nor routed by the Service are labeled
+// "other", so that clients can't create series at will.
+func (m *Metrics) methodLabel(method string) string {
+	if containsString(standardMethods, method) || m.service.routes[method] != nil {
+		return method
+	}
+	return "other"
+}
+
+func (m *Metrics) record(key metricKey, status int, d time.Duration, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight[key]--
+
+	key.status = strconv.Itoa(status/100) + "xx"
+	rm := m.requests[key]
+	if rm == nil {
+		rm = &requestMetrics{}
+		m.requests[key] = rm
+	}
+	rm.count++
+	rm.duration.observe(m.durationBuckets, d.Seconds())
+	rm.size.observe(m.sizeBuckets, float64(size))
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	m.mu.Lock()
+	inFlight := make(map[metricKey]int64, len(m.inFlight))
+	for k, v := range m.inFlight {
+		inFlight[k] = v
+	}
+	requests := make(map[metricKey]requestMetrics, len(m.requests))
+	for k, v := range m.requests {
+		rm := *v
+		rm.duration.counts = append([]uint64(nil), v.duration.counts...)
+		rm.size.counts = append([]uint64(nil), v.size.counts...)
+		requests[k] = rm
+	}
+	m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	writeMetricHeader(bw, "siesta_requests_in_flight", "gauge", "Number of requests being served.")
+	for _, k := range sortedKeys(inFlight) {
+		writeSample(bw, "siesta_requests_in_flight", k, "", strconv.FormatInt(inFlight[k], 10))
+	}
+
+	keys := make([]metricKey, 0, len(requests))
+	for k := range requests {
+		keys = append(keys, k)
+	}
+	sortMetricKeys(keys)
+
+	writeMetricHeader(bw, "siesta_requests_total", "counter", "Number of requests served.")
+	for _, k := range keys {
+		writeSample(bw, "siesta_requests_total", k, "", strconv.FormatUint(requests[k].count, 10))
+	}
+
+	writeMetricHeader(bw, "siesta_request_duration_seconds", "histogram", "Time taken to serve requests.")
+	for _, k := range keys {
+		rm := requests[k]
+		writeHistogram(bw, "siesta_request_duration_seconds", k, m.durationBuckets, &rm.duration, rm.count)
+	}
+
+	writeMetricHeader(bw, "siesta_response_size_bytes", "histogram", "Size of response bodies.")
+	for _, k := range keys {
+		rm := requests[k]
+		writeHistogram(bw, "siesta_response_size_bytes", k, m.sizeBuckets, &rm.size, rm.count)
+	}
+}
+
+func sortedKeys(series map[metricKey]int64) []metricKey {
+	keys := make([]metricKey, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sortMetricKeys(keys)
+	return keys
+}
+
+func sortMetricKeys(keys []metricKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+}
+
+func writeMetricHeader(bw *bufio.Writer, name, typ, help string) {
+	bw.WriteString("# HELP " + name + " " + help + "\n")
+	bw.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func writeHistogram(bw *bufio.Writer, name string, k metricKey, bounds []float64, h *histogram, count uint64) {
+	var cumulative uint64
+	for i, bound := range bounds {
+		if i < len(h.counts) {
+			cumulative += h.counts[i]
+		}
+		writeSample(bw, name+"_bucket", k, formatFloat(bound), strconv.FormatUint(cumulative, 10))
+	}
+	writeSample(bw, name+"_bucket", k, "+Inf", strconv.FormatUint(count, 10))
+	writeSample(bw, name+"_sum", k, "", formatFloat(h.sum))
+	writeSample(bw, name+"_count", k, "", strconv.FormatUint(count, 10))
+}
+
+// writeSample writes a sample line. le is the bucket label of
+// histograms, and is omitted if empty, like the status of k.
+func writeSample(bw *bufio.Writer, name string, k metricKey, le, value string) {
+	bw.WriteString(name)
+	bw.WriteString(`{method="`)
+	bw.WriteString(escapeLabel(k.method))
+	bw.WriteString(`",route="`)
+	bw.WriteString(escapeLabel(k.route))
+	if k.status != "" {
+		bw.WriteString(`",status="`)
+		bw.WriteString(k.status)
+	}
+	if le != "" {
+		bw.WriteString(`",le="`)
+		bw.WriteString(le)
+	}
+	bw.WriteString(`"} `)
+	bw.WriteString(value)
+	bw.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,90 @@
+package siesta
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	s := NewService("metrics")
+	m := NewMetrics(s)
+	m.SetDurationBuckets(60, 1)
+	m.SetSizeBuckets(10, 1000)
+
+	s.Route(http.MethodGet, "/items/:id", "Gets an item", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	s.Route(http.MethodGet, "/metrics", "Serves metrics", m.ServeHTTP)
+
+	h := m.Wrap(NewAccessLogger(ioutil.Discard, CommonLogFormat).Wrap(s))
+	for _, path := range []string{"/metrics/items/1", "/metrics/items/2", "/metrics/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/metrics", nil))
+	if want, got := "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"); want != got {
+		t.Errorf("expected content type %q got %q", want, got)
+	}
+
+	body := w.Body.String()
+	for _, line := range []string{
+		`siesta_requests_in_flight{method="GET",route="/metrics/items/:id"} 0`,
+		`siesta_requests_in_flight{method="GET",route="/metrics/metrics"} 1`,
+		`siesta_requests_total{method="GET",route="/metrics/items/:id",status="2xx"} 2`,
+		`siesta_requests_total{method="GET",route="",status="4xx"} 1`,
+		`siesta_request_duration_seconds_bucket{method="GET",route="/metrics/items/:id",status="2xx",le="1"} 2`,
+		`siesta_request_duration_seconds_bucket{method="GET",route="/metrics/items/:id",status="2xx",le="+Inf"} 2`,
+		`siesta_request_duration_seconds_count{method="GET",route="/metrics/items/:id",status="2xx"} 2`,
+		`siesta_response_size_bytes_bucket{method="GET",route="/metrics/items/:id",status="2xx",le="10"} 2`,
+		`siesta_response_size_bytes_sum{method="GET",route="/metrics/items/:id",status="2xx"} 10`,
+		`# TYPE siesta_response_size_bytes histogram`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	m := NewMetrics(NewService("metrics-escaping"))
+	m.record(metricKey{method: "GET", route: `/a"b\c`}, http.StatusOK, 0, 0)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if want := `route="/a\"b\\c"`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected %s in:\n%s", want, w.Body.String())
+	}
+}
+
+func TestMetricsUnknownMethods(t *testing.T) {
+	s := NewService("metrics-methods")
+	m := NewMetrics(s)
+	s.Route("PURGE", "/cache", "Purges the cache", func(w http.ResponseWriter, r *http.Request) {})
+
+	h := m.Wrap(s)
+	for _, method := range []string{"PURGE", "FOO", "BAR", "BAZ"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/metrics-methods/cache", nil))
+	}
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := w.Body.String()
+	for _, line := range []string{
+		`siesta_requests_total{method="PURGE",route="/metrics-methods/cache",status="2xx"} 1`,
+		`siesta_requests_total{method="other",route="",status="4xx"} 3`,
+		`siesta_requests_in_flight{method="other",route=""} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, body)
+		}
+	}
+	for _, method := range []string{"FOO", "BAR", "BAZ"} {
+		if strings.Contains(body, method) {
+			t.Errorf("expected no series for method %s in:\n%s", method, body)
+		}
+	}
+}
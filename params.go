@@ -3,12 +3,38 @@ package siesta
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// DefaultAllowedParams are the parameters accepted by every strict Params
+// without being defined, such as JSONP callbacks and cache busters.
+var DefaultAllowedParams = []string{"callback", "_"}
+
+// UnknownParamsError is returned by a strict Params when
+// parsing parameters that are not defined.
+type UnknownParamsError struct {
+	Names []string
+}
+
+func (e *UnknownParamsError) Error() string {
+	return fmt.Sprintf("unknown params '%s'", strings.Join(e.Names, "', '"))
+}
+
+// MissingParamsError is returned by Params.Parse when
+// required parameters are not given.
+type MissingParamsError struct {
+	Names []string
+}
+
+func (e *MissingParamsError) Error() string {
+	return fmt.Sprintf("missing required params '%s'", strings.Join(e.Names, "', '"))
+}
+
 // Params represents a set of URL parameters from a request's query string.
 // The interface is similar to a flag.FlagSet, but a) there is no usage string,
 // b) there are no custom Var()s, and c) there are SliceXXX types. Sliced types
@@ -16,17 +42,99 @@ import (
 // multiple times, and using a comma-delimited string. This adds the limitation
 // that you can't have a value with a comma if in a Sliced type.
 // Under the covers, Params uses flag.FlagSet.
+//
+// Parameters come from the form by default. The definers take an optional
+// ParamSource to have ParseRequest take them from elsewhere, as in
+//
+//	pageSize := params.Int("X-Page-Size", 50, "Page size", siesta.FromHeader)
 type Params struct {
 	fset *flag.FlagSet
+
+	strict   bool
+	collect  bool
+	allowed  map[string]bool
+	required []string
+	set      map[string]bool
+
+	constraints map[string][]Constraint
+	sources     map[string]ParamSource
+}
+
+// Required marks parameters as required. Parse returns a *MissingParamsError
+// naming every required parameter that is not given.
+func (rp *Params) Required(names ...string) {
+	rp.required = append(rp.required, names...)
+}
+
+// IsSet reports whether the parameter was given in the last call to Parse,
+// as opposed to having its default value.
+func (rp *Params) IsSet(name string) bool {
+	return rp.set[name]
+}
+
+// SetStrict sets whether Parse returns an *UnknownParamsError listing
+// the given parameters that are not defined. Parameters in
+// DefaultAllowedParams or added with Allow are accepted regardless.
+func (rp *Params) SetStrict(strict bool) {
+	rp.strict = strict
+}
+
+// SetCollectErrors sets whether Parse goes on after finding a problem
+// with the parameters, and returns a ParamErrors describing all of them
+// instead of the first one.
+func (rp *Params) SetCollectErrors(collect bool) {
+	rp.collect = collect
+}
+
+// Allow adds parameters that a strict Params accepts and ignores.
+func (rp *Params) Allow(names ...string) {
+	if rp.allowed == nil {
+		rp.allowed = map[string]bool{}
+	}
+	for _, name := range names {
+		rp.allowed[name] = true
+	}
+}
+
+// unknown returns the sorted names in args that are neither defined nor allowed.
+func (rp *Params) unknown(args url.Values) []string {
+	var names []string
+	for name := range args {
+		if rp.fset.Lookup(name) == nil && !rp.allowed[name] && !containsString(DefaultAllowedParams, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 // Parse parses URL parameters from a http.Request.URL.Query(), which is a
 // url.Values, which is just a map[string][string].
+// Parameters that are not defined are ignored, unless rp is strict.
 func (rp *Params) Parse(args url.Values) error {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
 
+	var errs ParamErrors
+
+	if rp.strict {
+		if names := rp.unknown(args); len(names) > 0 {
+			if !rp.collect {
+				return &UnknownParamsError{Names: names}
+			}
+			for _, name := range names {
+				errs = append(errs, &ParamError{
+					Name:   name,
+					Value:  strings.Join(args[name], ","),
+					Reason: "is not a known parameter",
+				})
+			}
+		}
+	}
+
+	rp.set = map[string]bool{}
+
 	// Parse items from URL query string
 FLAG_LOOP:
 	for name, vals := range args {
@@ -42,6 +150,7 @@ FLAG_LOOP:
 			if v == "" {
 				if bv, ok := f.Value.(boolFlag); ok && bv.IsBoolFlag() {
 					bv.Set("true")
+					rp.set[name] = true
 
 					continue FLAG_LOOP
 				}
@@ -50,47 +159,133 @@ FLAG_LOOP:
 			err := rp.fset.Set(name, v)
 			if err != nil {
 				// Remove the "flag" error message and make a "params" one.
-				// TODO: optionally allow undefined params to be given, but ignored?
 				if !strings.Contains(err.Error(), "no such flag -") {
+					if rp.collect {
+						errs = append(errs, invalidParam(name, v, f.Value))
+						continue FLAG_LOOP
+					}
 					// Give a helpful message about which param caused the error
 					err = fmt.Errorf("bad param '%s': %s", name, err.Error())
 					return err
 				}
 			}
+			rp.set[name] = true
 		}
 	}
 
+	var missing []string
+	for _, name := range rp.required {
+		if !rp.set[name] && len(args[name]) == 0 {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		if !rp.collect {
+			return &MissingParamsError{Names: missing}
+		}
+		for _, name := range missing {
+			e := &ParamError{Name: name, Reason: "is required"}
+			if f := rp.fset.Lookup(name); f != nil {
+				e.Type = paramTypeName(f.Value)
+			}
+			errs = append(errs, e)
+		}
+	}
+
+	violations := rp.validate(args)
+	if !rp.collect {
+		if len(violations) > 0 {
+			return violations[0]
+		}
+		return nil
+	}
+
+	errs = append(errs, violations...)
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			return errs[i].Name < errs[j].Name
+		})
+		return errs
+	}
 	return nil
 }
 
+// ParseRequest parses parameters from a http.Request, taking each from its
+// ParamSource. If the form is needed and has not been parsed yet, as with
+// the ParseLazily form policy, it is parsed first, including multipart bodies.
+// Form values named after parameters with other sources are ignored.
+func (rp *Params) ParseRequest(r *http.Request) error {
+	if r.Form == nil && rp.readsForm() {
+		if err := parseLazyForm(r); err != nil {
+			return err
+		}
+	}
+	if len(rp.sources) == 0 {
+		return rp.Parse(r.Form)
+	}
+
+	args := url.Values{}
+	for name, vals := range r.Form {
+		if rp.sources[name] == FromForm {
+			args[name] = vals
+		}
+	}
+	for name, source := range rp.sources {
+		if vals := source.values(r, name); len(vals) > 0 {
+			args[name] = vals
+		}
+	}
+	return rp.Parse(args)
+}
+
+// paramTypeNames are the names of the types of the parameters
+// reported by Usage, keyed by the type of their flag.Value.
+var paramTypeNames = map[string]string{
+	"*flag.stringValue":   "string",
+	"*flag.durationValue": "duration",
+	"*flag.intValue":      "int",
+	"*flag.boolValue":     "bool",
+	"*flag.float64Value":  "float64",
+	"*flag.int64Value":    "int64",
+	"*flag.uintValue":     "uint",
+	"*flag.uint64Value":   "uint64",
+	"*siesta.SString":     "[]string",
+	"*siesta.SDuration":   "[]duration",
+	"*siesta.SInt":        "[]int",
+	"*siesta.SBool":       "[]bool",
+	"*siesta.SFloat64":    "[]float64",
+	"*siesta.SInt64":      "[]int64",
+	"*siesta.SUint":       "[]uint",
+	"*siesta.SUint64":     "[]uint64",
+}
+
+func paramTypeName(v flag.Value) string {
+	t := fmt.Sprintf("%T", v)
+	if name := paramTypeNames[t]; name != "" {
+		return name
+	}
+	return t
+}
+
 // Usage returns a map keyed on parameter names. The map values are an array of
-// name, type, and usage information for each parameter.
+// name, type, and usage information for each parameter. The usage information
+// ends with the source and constraints of the parameter in parentheses, if any.
 func (rp *Params) Usage() map[string][3]string {
 	docs := make(map[string][3]string)
-	var translations map[string]string = map[string]string{
-		"*flag.stringValue":   "string",
-		"*flag.durationValue": "duration",
-		"*flag.intValue":      "int",
-		"*flag.boolValue":     "bool",
-		"*flag.float64Value":  "float64",
-		"*flag.int64Value":    "int64",
-		"*flag.uintValue":     "uint",
-		"*flag.uint64Value":   "uint64",
-		"*siesta.SString":     "[]string",
-		"*siesta.SDuration":   "[]duration",
-		"*siesta.SInt":        "[]int",
-		"*siesta.SBool":       "[]bool",
-		"*siesta.SFloat64":    "[]float64",
-		"*siesta.SInt64":      "[]int64",
-		"*siesta.SUint":       "[]uint",
-		"*siesta.SUint64":     "[]uint64",
-	}
 	rp.fset.VisitAll(func(flag *flag.Flag) {
-		niceName := translations[fmt.Sprintf("%T", flag.Value)]
-		if niceName == "" {
-			niceName = fmt.Sprintf("%T", flag.Value)
+		usage := flag.Usage
+		var notes []string
+		if source := rp.sources[flag.Name]; source != FromForm {
+			notes = append(notes, source.String())
+		}
+		if containsString(rp.required, flag.Name) {
+			notes = append(notes, "required")
+		}
+		notes = append(notes, describeConstraints(rp.constraints[flag.Name])...)
+		if len(notes) > 0 {
+			usage += " (" + strings.Join(notes, ", ") + ")"
 		}
-		docs[flag.Name] = [...]string{flag.Name, niceName, flag.Usage}
+		docs[flag.Name] = [...]string{flag.Name, paramTypeName(flag.Value), usage}
 	})
 	return docs
 }
@@ -102,10 +297,11 @@ type boolFlag interface {
 
 // Bool defines a bool param with specified name and default value.
 // The return value is the address of a bool variable that stores the value of the param.
-func (rp *Params) Bool(name string, value bool, usage string) *bool {
+func (rp *Params) Bool(name string, value bool, usage string, source ...ParamSource) *bool {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(bool)
 	rp.fset.BoolVar(p, name, value, usage)
 	return p
@@ -138,10 +334,11 @@ func (s *SBool) Set(value string) error {
 
 // SliceBool defines a multi-value bool param with specified name and default value.
 // The return value is the address of a SBool variable that stores the values of the param.
-func (rp *Params) SliceBool(name string, value bool, usage string) *SBool {
+func (rp *Params) SliceBool(name string, value bool, usage string, source ...ParamSource) *SBool {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(SBool)
 	rp.fset.Var(p, name, usage)
 	return p
@@ -149,10 +346,11 @@ func (rp *Params) SliceBool(name string, value bool, usage string) *SBool {
 
 // Int defines an int param with specified name and default value.
 // The return value is the address of an int variable that stores the value of the param.
-func (rp *Params) Int(name string, value int, usage string) *int {
+func (rp *Params) Int(name string, value int, usage string, source ...ParamSource) *int {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(int)
 	rp.fset.IntVar(p, name, value, usage)
 	return p
@@ -185,10 +383,11 @@ func (s *SInt) Set(value string) error {
 
 // SliceInt defines a multi-value int param with specified name and default value.
 // The return value is the address of a SInt variable that stores the values of the param.
-func (rp *Params) SliceInt(name string, value int, usage string) *SInt {
+func (rp *Params) SliceInt(name string, value int, usage string, source ...ParamSource) *SInt {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(SInt)
 	rp.fset.Var(p, name, usage)
 	return p
@@ -196,10 +395,11 @@ func (rp *Params) SliceInt(name string, value int, usage string) *SInt {
 
 // Int64 defines an int64 param with specified name and default value.
 // The return value is the address of an int64 variable that stores the value of the param.
-func (rp *Params) Int64(name string, value int64, usage string) *int64 {
+func (rp *Params) Int64(name string, value int64, usage string, source ...ParamSource) *int64 {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(int64)
 	rp.fset.Int64Var(p, name, value, usage)
 	return p
@@ -232,10 +432,11 @@ func (s *SInt64) Set(value string) error {
 
 // SliceInt64 defines a multi-value int64 param with specified name and default value.
 // The return value is the address of a SInt64 variable that stores the values of the param.
-func (rp *Params) SliceInt64(name string, value int64, usage string) *SInt64 {
+func (rp *Params) SliceInt64(name string, value int64, usage string, source ...ParamSource) *SInt64 {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(SInt64)
 	rp.fset.Var(p, name, usage)
 	return p
@@ -243,10 +444,11 @@ func (rp *Params) SliceInt64(name string, value int64, usage string) *SInt64 {
 
 // Uint defines a uint param with specified name and default value.
 // The return value is the address of a uint variable that stores the value of the param.
-func (rp *Params) Uint(name string, value uint, usage string) *uint {
+func (rp *Params) Uint(name string, value uint, usage string, source ...ParamSource) *uint {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(uint)
 	rp.fset.UintVar(p, name, value, usage)
 	return p
@@ -279,10 +481,11 @@ func (s *SUint) Set(value string) error {
 
 // SliceUint defines a multi-value uint param with specified name and default value.
 // The return value is the address of a SUint variable that stores the values of the param.
-func (rp *Params) SliceUint(name string, value uint, usage string) *SUint {
+func (rp *Params) SliceUint(name string, value uint, usage string, source ...ParamSource) *SUint {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(SUint)
 	rp.fset.Var(p, name, usage)
 	return p
@@ -290,10 +493,11 @@ func (rp *Params) SliceUint(name string, value uint, usage string) *SUint {
 
 // Uint64 defines a uint64 param with specified name and default value.
 // The return value is the address of a uint64 variable that stores the value of the param.
-func (rp *Params) Uint64(name string, value uint64, usage string) *uint64 {
+func (rp *Params) Uint64(name string, value uint64, usage string, source ...ParamSource) *uint64 {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(uint64)
 	rp.fset.Uint64Var(p, name, value, usage)
 	return p
@@ -326,10 +530,11 @@ func (s *SUint64) Set(value string) error {
 
 // SliceUint64 defines a multi-value uint64 param with specified name and default value.
 // The return value is the address of a SUint64 variable that stores the values of the param.
-func (rp *Params) SliceUint64(name string, value uint64, usage string) *SUint64 {
+func (rp *Params) SliceUint64(name string, value uint64, usage string, source ...ParamSource) *SUint64 {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(SUint64)
 	rp.fset.Var(p, name, usage)
 	return p
@@ -337,10 +542,11 @@ func (rp *Params) SliceUint64(name string, value uint64, usage string) *SUint64
 
 // String defines a string param with specified name and default value.
 // The return value is the address of a string variable that stores the value of the param.
-func (rp *Params) String(name string, value string, usage string) *string {
+func (rp *Params) String(name string, value string, usage string, source ...ParamSource) *string {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(string)
 	rp.fset.StringVar(p, name, value, usage)
 	return p
@@ -367,10 +573,11 @@ func (s *SString) Set(value string) error {
 
 // SliceString defines a multi-value string param with specified name and default value.
 // The return value is the address of a SString variable that stores the values of the param.
-func (rp *Params) SliceString(name string, value string, usage string) *SString {
+func (rp *Params) SliceString(name string, value string, usage string, source ...ParamSource) *SString {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(SString)
 	rp.fset.Var(p, name, usage)
 	return p
@@ -378,10 +585,11 @@ func (rp *Params) SliceString(name string, value string, usage string) *SString
 
 // Float64 defines a float64 param with specified name and default value.
 // The return value is the address of a float64 variable that stores the value of the param.
-func (rp *Params) Float64(name string, value float64, usage string) *float64 {
+func (rp *Params) Float64(name string, value float64, usage string, source ...ParamSource) *float64 {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(float64)
 	rp.fset.Float64Var(p, name, value, usage)
 	return p
@@ -414,10 +622,11 @@ func (s *SFloat64) Set(value string) error {
 
 // SliceFloat64 defines a multi-value float64 param with specified name and default value.
 // The return value is the address of a SFloat64 variable that stores the values of the param.
-func (rp *Params) SliceFloat64(name string, value float64, usage string) *SFloat64 {
+func (rp *Params) SliceFloat64(name string, value float64, usage string, source ...ParamSource) *SFloat64 {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(SFloat64)
 	rp.fset.Var(p, name, usage)
 	return p
@@ -425,10 +634,11 @@ func (rp *Params) SliceFloat64(name string, value float64, usage string) *SFloat
 
 // Duration defines a time.Duration param with specified name and default value.
 // The return value is the address of a time.Duration variable that stores the value of the param.
-func (rp *Params) Duration(name string, value time.Duration, usage string) *time.Duration {
+func (rp *Params) Duration(name string, value time.Duration, usage string, source ...ParamSource) *time.Duration {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(time.Duration)
 	rp.fset.DurationVar(p, name, value, usage)
 	return p
@@ -461,10 +671,11 @@ func (s *SDuration) Set(value string) error {
 
 // SliceDuration defines a multi-value time.Duration param with specified name and default value.
 // The return value is the address of a SDuration variable that stores the values of the param.
-func (rp *Params) SliceDuration(name string, value time.Duration, usage string) *SDuration {
+func (rp *Params) SliceDuration(name string, value time.Duration, usage string, source ...ParamSource) *SDuration {
 	if rp.fset == nil {
 		rp.fset = flag.NewFlagSet("anonymous", flag.ExitOnError) // both args are unused.
 	}
+	rp.setSource(name, source)
 	p := new(SDuration)
 	rp.fset.Var(p, name, usage)
 	return p
@@ -208,3 +208,81 @@ func TestParamsSlices(t *testing.T) {
 	compareUsageMaps(t, usage, expected)
 
 }
+
+func TestParamsStrict(t *testing.T) {
+	v := url.Values{}
+	v.Set("limt", "10")
+	v.Set("offset", "5")
+	v.Set("callback", "cb")
+	v.Set("debug", "1")
+	v.Set("zzz", "")
+
+	p := Params{}
+	p.Int("limit", 20, "max results")
+	offset := p.Int("offset", 0, "first result")
+	if err := p.Parse(v); err != nil {
+		t.Fatalf("expected unknown params to be ignored, got %v", err)
+	}
+	if *offset != 5 {
+		t.Errorf("expected 5, got %d", *offset)
+	}
+
+	p.SetStrict(true)
+	p.Allow("debug")
+	err := p.Parse(v)
+	unknown, ok := err.(*UnknownParamsError)
+	if !ok {
+		t.Fatalf("expected an *UnknownParamsError, got %v", err)
+	}
+	if len(unknown.Names) != 2 || unknown.Names[0] != "limt" || unknown.Names[1] != "zzz" {
+		t.Errorf("expected [limt zzz], got %v", unknown.Names)
+	}
+	if want, got := "unknown params 'limt', 'zzz'", err.Error(); want != got {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	v.Del("limt")
+	v.Del("zzz")
+	if err := p.Parse(v); err != nil {
+		t.Errorf("expected allowed params to be accepted, got %v", err)
+	}
+}
+
+func TestParamsRequired(t *testing.T) {
+	p := Params{}
+	p.Int("id", 0, "resource identifier")
+	p.String("name", "", "resource name")
+	p.Bool("verbose", false, "verbose output")
+	limit := p.Int("limit", 20, "max results")
+	p.Required("id", "name")
+
+	v := url.Values{}
+	v.Set("verbose", "")
+	err := p.Parse(v)
+	missing, ok := err.(*MissingParamsError)
+	if !ok {
+		t.Fatalf("expected a *MissingParamsError, got %v", err)
+	}
+	if want, got := "missing required params 'id', 'name'", missing.Error(); want != got {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if !p.IsSet("verbose") {
+		t.Error("expected verbose to be set")
+	}
+
+	v.Set("id", "7")
+	v.Set("name", "")
+	if err := p.Parse(v); err != nil {
+		t.Fatal(err)
+	}
+	if p.IsSet("limit") || *limit != 20 {
+		t.Errorf("expected limit to have its default, got %d", *limit)
+	}
+	if !p.IsSet("id") || !p.IsSet("name") {
+		t.Error("expected id and name to be set")
+	}
+
+	if want, got := "resource identifier (required)", p.Usage()["id"][2]; want != got {
+		t.Errorf("expected usage %q, got %q", want, got)
+	}
+}
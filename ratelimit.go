@@ -0,0 +1,226 @@
+package siesta
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit is the number of requests allowed per period. Requests
+// are allowed in bursts of up to Requests, with capacity restored
+// at a steady rate over Period. Requests must be positive.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// RateLimitStatus is the outcome of taking a request from a RateLimitStore.
+type RateLimitStatus struct {
+	// Allowed reports whether the request may proceed.
+	Allowed bool
+	// Remaining is the number of requests left in the current burst.
+	Remaining int
+	// Reset is the time until the full burst is available again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed.
+	// It is zero if Allowed is true.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the state of rate limits. Implementations must be
+// safe for concurrent use. A store shared between processes lets several
+// instances of a Service enforce a common limit.
+type RateLimitStore interface {
+	// Take takes one request for key at time now.
+	Take(key string, limit RateLimit, now time.Time) (RateLimitStatus, error)
+}
+
+// RateLimitKeyFunc returns the key a request is limited by.
+// Requests with an empty key are not limited.
+type RateLimitKeyFunc func(c Context, r *http.Request) string
+
+// ClientIPKey limits requests by the IP address of the client.
+func ClientIPKey(c Context, r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ContextKey limits requests by the value stored in the Context
+// under key, such as an authenticated user.
+func ContextKey(key string) RateLimitKeyFunc {
+	return func(c Context, r *http.Request) string {
+		v := c.Get(key)
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+// HeaderKey limits requests by the value of a request header,
+// such as an API key.
+func HeaderKey(header string) RateLimitKeyFunc {
+	return func(c Context, r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// RouteKey limits requests by the pattern of the route of s
+// they match. Unmatched requests are not limited.
+func RouteKey(s *Service) RateLimitKeyFunc {
+	return func(c Context, r *http.Request) string {
+		if pattern := s.pattern(r.Method, r.URL.Path); pattern != "" {
+			return r.Method + " " + pattern
+		}
+		return ""
+	}
+}
+
+// RateLimiter limits the rate of requests. Its Handle method should
+// be added to the "pre" chain; requests over the limit get a
+// 429 Too Many Requests response and the chain quits.
+//
+// Responses to requests with a key carry the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers, and limited ones
+// Retry-After too. Requests are allowed if the store fails.
+type RateLimiter struct {
+	limit RateLimit
+	key   RateLimitKeyFunc
+	store RateLimitStore
+}
+
+// NewRateLimiter returns a RateLimiter allowing limit requests per key.
+// It keeps its state in a new MemoryRateLimitStore. It panics if
+// limit.Requests is not positive.
+func NewRateLimiter(limit RateLimit, key RateLimitKeyFunc) *RateLimiter {
+	if limit.Requests <= 0 {
+		panic("siesta: RateLimit.Requests must be positive")
+	}
+	return &RateLimiter{
+		limit: limit,
+		key:   key,
+		store: NewMemoryRateLimitStore(),
+	}
+}
+
+// SetStore sets the store the limiter keeps its state in.
+func (l *RateLimiter) SetStore(store RateLimitStore) {
+	l.store = store
+}
+
+// Handle is a "pre" handler that enforces the rate limit.
+func (l *RateLimiter) Handle(c Context, w http.ResponseWriter, r *http.Request, quit func()) {
+	key := l.key(c, r)
+	if key == "" {
+		return
+	}
+
+	status, err := l.store.Take(key, l.limit, time.Now())
+	if err != nil {
+		return
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(l.limit.Requests))
+	h.Set("RateLimit-Remaining", strconv.Itoa(status.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(status.Reset)))
+
+	if !status.Allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(status.RetryAfter)))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		quit()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimitShards is the number of shards of a MemoryRateLimitStore.
+const rateLimitShards = 32
+
+// MemoryRateLimitStore is an in-memory token bucket RateLimitStore.
+// Keys are spread over several shards to reduce lock contention.
+type MemoryRateLimitStore struct {
+	shards [rateLimitShards]rateLimitShard
+}
+
+type rateLimitShard struct {
+	sync.Mutex
+	buckets map[string]*tokenBucket
+	takes   int
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{}
+	for i := range s.shards {
+		s.shards[i].buckets = map[string]*tokenBucket{}
+	}
+	return s
+}
+
+// Take satisfies the RateLimitStore interface.
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) (RateLimitStatus, error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &s.shards[h.Sum32()%rateLimitShards]
+
+	if limit.Requests <= 0 {
+		return RateLimitStatus{}, errors.New("siesta: RateLimit.Requests must be positive")
+	}
+	capacity := float64(limit.Requests)
+	perToken := limit.Period / time.Duration(limit.Requests)
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	shard.takes++
+	if shard.takes%1024 == 0 {
+		// Drop the buckets that have refilled completely, since
+		// they are equivalent to missing ones.
+		for k, b := range shard.buckets {
+			if now.Sub(b.updated) >= b.period {
+				delete(shard.buckets, k)
+			}
+		}
+	}
+
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, updated: now, period: limit.Period}
+		shard.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated)
+	if elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+float64(elapsed)/float64(perToken))
+		b.updated = now
+	}
+
+	status := RateLimitStatus{}
+	if b.tokens >= 1 {
+		b.tokens--
+		status.Allowed = true
+	} else {
+		status.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	status.Remaining = int(b.tokens)
+	status.Reset = time.Duration((capacity - b.tokens) * float64(perToken))
+	return status, nil
+}
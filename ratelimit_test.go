@@ -0,0 +1,85 @@
+package siesta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	limit := RateLimit{Requests: 2, Period: 2 * time.Second}
+	now := time.Now()
+
+	for i, want := range []bool{true, true, false} {
+		status, err := s.Take("k", limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Allowed != want {
+			t.Fatalf("take %d: expected allowed %t got %t", i, want, status.Allowed)
+		}
+	}
+
+	status, _ := s.Take("k", limit, now)
+	if want, got := time.Second, status.RetryAfter; want != got {
+		t.Errorf("expected retry after %s got %s", want, got)
+	}
+
+	status, _ = s.Take("k", limit, now.Add(time.Second))
+	if !status.Allowed {
+		t.Error("expected a token to be refilled")
+	}
+
+	status, _ = s.Take("other", limit, now)
+	if want, got := 1, status.Remaining; !status.Allowed || want != got {
+		t.Errorf("expected an independent bucket with %d remaining, got %d", want, got)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	s := NewService("limited")
+	s.AddPre(NewRateLimiter(RateLimit{Requests: 1, Period: time.Minute}, RouteKey(s)).Handle)
+
+	handled := 0
+	s.Route(http.MethodGet, "/items/:id", "Gets an item", func(w http.ResponseWriter, r *http.Request) {
+		handled++
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/limited/items/1", nil))
+	if want, got := http.StatusOK, w.Code; want != got {
+		t.Fatalf("expected status %d got %d", want, got)
+	}
+	if want, got := "0", w.Header().Get("RateLimit-Remaining"); want != got {
+		t.Errorf("expected remaining %s got %s", want, got)
+	}
+
+	// Same route, different path.
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/limited/items/2", nil))
+	if want, got := http.StatusTooManyRequests, w.Code; want != got {
+		t.Fatalf("expected status %d got %d", want, got)
+	}
+	if want, got := "60", w.Header().Get("Retry-After"); want != got {
+		t.Errorf("expected retry after %s got %s", want, got)
+	}
+	if want, got := 1, handled; want != got {
+		t.Errorf("expected %d handled requests got %d", want, got)
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	limit := RateLimit{Requests: 0, Period: time.Second}
+	if _, err := NewMemoryRateLimitStore().Take("k", limit, time.Now()); err == nil {
+		t.Error("expected an error for a limit without requests")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected NewRateLimiter to panic")
+		}
+	}()
+	NewRateLimiter(limit, ClientIPKey)
+}
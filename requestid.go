@@ -0,0 +1,90 @@
+package siesta
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRequestIDChars are the characters allowed in incoming request IDs
+// unless changed with RequestIdentifier.SetAllowedChars.
+const DefaultRequestIDChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_.:"
+
+// requestIDFallback is used to keep IDs unique if the system's
+// random number generator fails.
+var requestIDFallback uint64
+
+// RequestIdentifier assigns an ID to every request. IDs sent by
+// the client are honored if they are valid; a new random ID is
+// generated otherwise. The ID is stored in the Context under
+// RequestIDContextKey and echoed in the response header.
+type RequestIdentifier struct {
+	header       string
+	maxLength    int
+	allowedChars string
+}
+
+// NewRequestIdentifier returns a RequestIdentifier that uses the
+// X-Request-ID header and accepts incoming IDs of up to 128 characters
+// from DefaultRequestIDChars.
+func NewRequestIdentifier() *RequestIdentifier {
+	return &RequestIdentifier{
+		header:       "X-Request-ID",
+		maxLength:    128,
+		allowedChars: DefaultRequestIDChars,
+	}
+}
+
+// SetHeader sets the request and response header carrying the ID.
+func (ri *RequestIdentifier) SetHeader(header string) {
+	ri.header = header
+}
+
+// SetMaxLength sets the maximum length of incoming IDs.
+// Longer IDs are replaced with a generated one.
+func (ri *RequestIdentifier) SetMaxLength(n int) {
+	ri.maxLength = n
+}
+
+// SetAllowedChars sets the characters allowed in incoming IDs.
+// IDs containing other characters are replaced with a generated one.
+func (ri *RequestIdentifier) SetAllowedChars(chars string) {
+	ri.allowedChars = chars
+}
+
+// Handle is a "pre" handler that identifies the request.
+func (ri *RequestIdentifier) Handle(c Context, w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(ri.header)
+	if !ri.valid(id) {
+		id = newRequestID()
+	}
+
+	c.Set(RequestIDContextKey, id)
+	w.Header().Set(ri.header, id)
+}
+
+func (ri *RequestIdentifier) valid(id string) bool {
+	if id == "" || len(id) > ri.maxLength {
+		return false
+	}
+	for _, ch := range id {
+		if !strings.ContainsRune(ri.allowedChars, ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit ID encoded in hexadecimal.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		n := atomic.AddUint64(&requestIDFallback, 1)
+		return strconv.FormatInt(time.Now().UnixNano(), 16) + "-" + strconv.FormatUint(n, 16)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,59 @@
+package siesta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIdentifier(t *testing.T) {
+	ri := NewRequestIdentifier()
+
+	for _, test := range []struct {
+		incoming string
+		honored  bool
+	}{
+		{"", false},
+		{"abc-123_def.456:7", true},
+		{"abc def", false},
+		{"<script>", false},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+	} {
+		c := NewSiestaContext()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Request-ID", test.incoming)
+		ri.Handle(c, w, r)
+
+		id, _ := c.Get(RequestIDContextKey).(string)
+		if id == "" {
+			t.Fatalf("%q: expected a request ID", test.incoming)
+		}
+		if want, got := id, w.Header().Get("X-Request-ID"); want != got {
+			t.Errorf("%q: expected header %q got %q", test.incoming, want, got)
+		}
+		if got := id == test.incoming; got != test.honored {
+			t.Errorf("%q: expected honored %t got %t", test.incoming, test.honored, got)
+		}
+	}
+}
+
+func TestRequestIdentifierUnique(t *testing.T) {
+	ri := NewRequestIdentifier()
+	ri.SetHeader("X-Trace")
+
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		c := NewSiestaContext()
+		w := httptest.NewRecorder()
+		ri.Handle(c, w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		id := w.Header().Get("X-Trace")
+		if seen[id] {
+			t.Fatalf("duplicate request ID %q", id)
+		}
+		seen[id] = true
+	}
+}
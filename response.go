@@ -0,0 +1,120 @@
+package siesta
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps an http.ResponseWriter and records the status code
+// and the number of bytes written to the client.
+type responseWriter struct {
+	http.ResponseWriter
+
+	status int
+	size   int64
+
+	// finishers run in reverse order once the request is served.
+	finishers []func()
+
+	// headerHooks run with the status code before the header is written.
+	headerHooks []func(status int)
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w}
+}
+
+// WriteHeader records the status code before sending it.
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.writingHeader(code)
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the number of bytes written. The status code
+// defaults to 200 if WriteHeader has not been called.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.writingHeader(http.StatusOK)
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// filter makes dst the destination of w. dst must write to the
+// previous destination, w.ResponseWriter. finish is called once the
+// request has been served.
+func (w *responseWriter) filter(dst http.ResponseWriter, finish func()) {
+	w.ResponseWriter = dst
+	w.finishers = append(w.finishers, finish)
+}
+
+// beforeHeader adds a function that is called right before
+// the header is written, while it can still be modified.
+func (w *responseWriter) beforeHeader(f func(status int)) {
+	w.headerHooks = append(w.headerHooks, f)
+}
+
+// writingHeader runs the header hooks once.
+func (w *responseWriter) writingHeader(status int) {
+	hooks := w.headerHooks
+	w.headerHooks = nil
+	for _, f := range hooks {
+		f(status)
+	}
+}
+
+// finish runs the finishers of the filters.
+func (w *responseWriter) finish() {
+	for i := len(w.finishers) - 1; i >= 0; i-- {
+		w.finishers[i]()
+	}
+	w.finishers = nil
+}
+
+// Status returns the status code sent to the client,
+// or 0 if nothing has been written yet.
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of body bytes sent to the client.
+func (w *responseWriter) Size() int64 {
+	return w.size
+}
+
+// Flush satisfies the http.Flusher interface. It is a no-op
+// if the underlying ResponseWriter can't flush.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.writingHeader(http.StatusOK)
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack satisfies the http.Hijacker interface.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("siesta: ResponseWriter does not implement http.Hijacker")
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter.
+// It is used by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
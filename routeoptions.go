@@ -0,0 +1,41 @@
+package siesta
+
+import (
+	"time"
+)
+
+// routeOptionsContextKey is a special context key holding the
+// options of the route matching the request.
+const routeOptionsContextKey = nullByteStr + "route-options"
+
+// RouteOption configures the behavior of a Service for a single
+// route. See Service.Route.
+type RouteOption func(*routeOptions)
+
+// routeOptions holds the settings of a route. Unset
+// settings fall back to the Service-wide behavior.
+type routeOptions struct {
+	// compress overrides whether a Compressor compresses responses.
+	compress *bool
+
+	// maxBodySize overrides the maximum request body size.
+	maxBodySize *int64
+
+	// formPolicy overrides how the request form is parsed.
+	formPolicy *FormPolicy
+
+	// timeout overrides the timeout of the main handler.
+	timeout *time.Duration
+
+	// concurrency overrides the concurrency limit of the Service.
+	// It is set but nil for unlimited routes.
+	concurrency    *concurrencyLimit
+	hasConcurrency bool
+}
+
+// routeOptionsFrom returns the options of the route
+// stored in c, or nil if there are none.
+func routeOptionsFrom(c Context) *routeOptions {
+	opts, _ := c.Get(routeOptionsContextKey).(*routeOptions)
+	return opts
+}
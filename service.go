@@ -1,11 +1,11 @@
 package siesta
 
 import (
-	"io"
-	"io/ioutil"
 	"net/http"
 	"path"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Registered services keyed by base URI.
@@ -29,13 +29,51 @@ type Service struct {
 	baseURI   string
 	trimSlash bool
 
-	pre  []ContextHandler
-	post []ContextHandler
+	pre  []chainHandler
+	post []chainHandler
 
 	routes map[string]*node
 
+	// names of the route handlers, keyed by verb and pattern
+	names map[string]string
+
+	// options of each route, keyed by verb and pattern
+	options map[string]*routeOptions
+
 	notFound ContextHandler
 
+	// errorHandler handles errors detected by the Service
+	errorHandler ContextHandler
+
+	// maximum request body size, and maximum number of unread
+	// body bytes to discard before the response header
+	maxBodySize  int64
+	maxDrainSize int64
+
+	// timeout of the main handler
+	timeout time.Duration
+
+	// concurrency limits the number of main handlers running at once
+	concurrency *concurrencyLimit
+
+	// tracer traces requests when set
+	tracer *Tracer
+
+	// timings enables the recording of the timeline of requests
+	timings bool
+
+	hooks hooks
+
+	// drain tracks the requests in flight
+	drain drainState
+
+	// formPolicy is how request forms are parsed
+	formPolicy FormPolicy
+
+	// maxDecompressedSize enables the decompression of request
+	// bodies when greater than zero
+	maxDecompressedSize int64
+
 	// postExecutionFunc runs at the end of the request
 	postExecutionFunc func(c Context, r *http.Request, panicValue interface{})
 }
@@ -48,9 +86,12 @@ func NewService(baseURI string) *Service {
 	}
 
 	return &Service{
-		baseURI:   path.Join("/", baseURI, "/"),
-		routes:    map[string]*node{},
-		trimSlash: true,
+		baseURI:      path.Join("/", baseURI, "/"),
+		routes:       map[string]*node{},
+		names:        map[string]string{},
+		options:      map[string]*routeOptions{},
+		trimSlash:    true,
+		maxDrainSize: defaultMaxDrainSize,
 	}
 }
 
@@ -66,9 +107,15 @@ func (s *Service) DisableTrimSlash() {
 	s.trimSlash = false
 }
 
-func addToChain(f interface{}, chain []ContextHandler) []ContextHandler {
+// chainHandler is a handler in the "pre" or "post" chain.
+type chainHandler struct {
+	handler ContextHandler
+	name    string
+}
+
+func addToChain(f interface{}, chain []chainHandler) []chainHandler {
 	m := ToContextHandler(f)
-	return append(chain, m)
+	return append(chain, chainHandler{handler: m, name: handlerName(f)})
 }
 
 // AddPre adds f to the end of the "pre" chain.
@@ -92,24 +139,65 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // A Service will run through both of its internal chains, quitting
 // when requested.
 func (s *Service) ServeHTTPInContext(c Context, w http.ResponseWriter, r *http.Request) {
+	if !s.startRequest() {
+		w.Header().Set("Connection", "close")
+		s.serveError(c, w, r, &StatusError{Status: http.StatusServiceUnavailable, Err: ErrShuttingDown})
+		return
+	}
+	defer s.endRequest()
+
+	// sent records the response below the filters added to rw.
+	sent := &responseWriter{ResponseWriter: w}
+	rw := &responseWriter{ResponseWriter: sent}
+	tr := s.startTrace(c, w, r)
+	tl := s.startTimeline(rw)
+	s.startHooks(c, rw, r)
+
 	defer func() {
 		var e interface{}
 		// Check if there was a panic
 		e = recover()
+		status := responseStatus(sent, rw, e != nil)
+		if tr != nil {
+			tr.end(c, status, e)
+		}
+		s.endHooks(c, r, status, e)
 		// Run the post execution func if we have one
 		if s.postExecutionFunc != nil {
 			s.postExecutionFunc(c, r, e)
 		}
+		runCleanups(c)
 		if e != nil {
 			// Re-panic if we recovered
 			panic(e)
 		}
 	}()
-	r.ParseForm()
+
+	opts := s.optionsFor(r.Method, r.URL.Path)
+	if opts != nil {
+		c.Set(routeOptionsContextKey, opts)
+	}
+
+	// Errors found before running the chains are handled
+	// in place of the main handler.
+	reqErr := s.limitBody(w, r, opts)
+
+	w = rw
+
+	if s.maxDecompressedSize > 0 {
+		s.decompressRequest(r)
+	}
+	drainBody := s.drainBeforeHeader(rw, r)
+
+	policy := s.formPolicyFor(opts)
+	if err := parseForm(r, policy); err != nil && reqErr == nil {
+		reqErr = err
+	}
+	r = setQueryOnly(r, policy)
 
 	quit := false
 	for _, m := range s.pre {
-		m(c, w, r, func() {
+		s.instrument(tr, tl, "pre", m.name, m.handler)(c, w, r, func() {
 			quit = true
 		})
 
@@ -131,6 +219,7 @@ func (s *Service) ServeHTTPInContext(c Context, w http.ResponseWriter, r *http.R
 		var (
 			handler ContextHandler
 			usage   string
+			pattern string
 			params  routeParams
 		)
 
@@ -138,11 +227,29 @@ func (s *Service) ServeHTTPInContext(c Context, w http.ResponseWriter, r *http.R
 		routeNode, ok := s.routes[r.Method]
 
 		if ok {
-			handler, usage, params, _ = routeNode.getValue(r.URL.Path)
+			handler, usage, pattern, params, _ = routeNode.getValue(r.URL.Path)
 			c.Set(UsageContextKey, usage)
+			if handler != nil {
+				c.Set(RouteContextKey, pattern)
+				runHooks(s.hooks.routeMatched, c, r)
+			}
+		}
+
+		// release frees the concurrency slot of the main handler.
+		var release func()
+		if handler != nil && reqErr == nil {
+			if limit := s.concurrencyFor(opts); limit != nil {
+				if limit.acquire(r) {
+					release = limit.release
+				} else {
+					w.Header().Set("Retry-After", limit.retryAfter())
+					reqErr = &StatusError{Status: http.StatusServiceUnavailable, Err: ErrOverloaded}
+				}
+			}
 		}
 
 		if handler == nil {
+			runHooks(s.hooks.notFound, c, r)
 			if s.notFound != nil {
 				// Use user-defined handler.
 				s.notFound(c, w, r, func() {})
@@ -150,32 +257,49 @@ func (s *Service) ServeHTTPInContext(c Context, w http.ResponseWriter, r *http.R
 				// Default to the net/http NotFoundHandler.
 				http.NotFoundHandler().ServeHTTP(w, r)
 			}
+		} else if reqErr != nil {
+			s.serveError(c, w, r, reqErr)
 		} else {
-			for _, p := range params {
-				r.Form.Set(p.Key, p.Value)
-			}
+			r = setRouteParams(r, params)
+			handler = s.instrument(tr, tl, "main", s.names[r.Method+" "+pattern], handler)
 
-			handler(c, w, r, func() {
-				quit = true
-			})
+			if timeout := s.timeoutFor(opts); timeout > 0 {
+				ctx := newTimeoutContext(r.Context(), timeout)
+				defer ctx.cancel()
+				r = r.WithContext(ctx)
 
-			if r.Body != nil {
-				io.Copy(ioutil.Discard, r.Body)
-				r.Body.Close()
+				if !s.serveWithTimeout(ctx, handler, c, w, r, release) {
+					drainBody()
+				}
+			} else {
+				if release != nil {
+					defer release()
+				}
+				handler(c, w, r, func() {
+					quit = true
+				})
+
+				drainBody()
 			}
 		}
 	}
 
 	quit = false
 	for _, m := range s.post {
-		m(c, w, r, func() {
+		s.instrument(tr, tl, "post", m.name, m.handler)(c, w, r, func() {
 			quit = true
 		})
 
 		if quit {
-			return
+			break
 		}
 	}
+
+	if rw.Status() == 0 {
+		// Nothing was written, so the header is still pending.
+		rw.writingHeader(http.StatusOK)
+	}
+	rw.finish()
 }
 
 // Route adds a new route to the Service.
@@ -189,16 +313,78 @@ func (s *Service) ServeHTTPInContext(c Context, w http.ResponseWriter, r *http.R
 // Note that Context is an interface type defined in this package.
 // The last argument is a function which is called to signal the
 // quitting of the current execution sequence.
-func (s *Service) Route(verb, uriPath, usage string, f interface{}) {
+//
+// opts configure the behavior of the Service for this route only.
+func (s *Service) Route(verb, uriPath, usage string, f interface{}, opts ...RouteOption) {
 	handler := ToContextHandler(f)
 
 	if n := s.routes[verb]; n == nil {
 		s.routes[verb] = &node{}
 	}
 
-	s.routes[verb].addRoute(
-		path.Join(s.baseURI, strings.TrimRight(uriPath, "/")),
-		usage, handler)
+	pattern := path.Join(s.baseURI, strings.TrimRight(uriPath, "/"))
+	s.routes[verb].addRoute(pattern, usage, handler)
+	s.names[verb+" "+pattern] = handlerName(f)
+
+	if len(opts) > 0 {
+		o := &routeOptions{}
+		for _, opt := range opts {
+			opt(o)
+		}
+		s.options[verb+" "+pattern] = o
+	}
+}
+
+// instrument returns h wrapped to record its execution in the trace
+// tr and the timeline tl of the request, if any, and to run the quit hooks.
+func (s *Service) instrument(tr *requestTrace, tl *timeline, chain, name string, h ContextHandler) ContextHandler {
+	if tr != nil {
+		h = tr.trace(chain, name, h)
+	}
+	if tl != nil {
+		h = tl.time(chain, name, h)
+	}
+	return s.quitHooked(chain, name, h)
+}
+
+// optionsFor returns the options of the route matching verb
+// and uriPath, or nil if the route has none.
+func (s *Service) optionsFor(verb, uriPath string) *routeOptions {
+	if len(s.options) == 0 {
+		return nil
+	}
+	return s.options[verb+" "+s.pattern(verb, uriPath)]
+}
+
+// pattern returns the pattern of the route matching verb and uriPath,
+// or an empty string if there is none.
+func (s *Service) pattern(verb, uriPath string) string {
+	routeNode, ok := s.routes[verb]
+	if !ok {
+		return ""
+	}
+	if uriPath != "/" && s.trimSlash {
+		uriPath = strings.TrimRight(uriPath, "/")
+	}
+
+	_, _, pattern, _, _ := routeNode.getValue(uriPath)
+	return pattern
+}
+
+// methods returns the sorted list of verbs with a route matching uriPath.
+func (s *Service) methods(uriPath string) []string {
+	if uriPath != "/" && s.trimSlash {
+		uriPath = strings.TrimRight(uriPath, "/")
+	}
+
+	var verbs []string
+	for verb, routeNode := range s.routes {
+		if handler, _, _, _, _ := routeNode.getValue(uriPath); handler != nil {
+			verbs = append(verbs, verb)
+		}
+	}
+	sort.Strings(verbs)
+	return verbs
 }
 
 // SetNotFound sets the handler for all paths that do not
@@ -0,0 +1,101 @@
+package siesta
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/textproto"
+)
+
+// ParamSource is the part of a request that Params.ParseRequest
+// takes a parameter from.
+type ParamSource int
+
+const (
+	// FromForm takes the parameter from http.Request.Form,
+	// which includes route parameters. This is the default.
+	FromForm ParamSource = iota
+	// FromHeader takes the parameter from the request header
+	// of the same name, as in X-Page-Size.
+	FromHeader
+	// FromCookie takes the parameter from the cookie of the same name.
+	FromCookie
+	// FromPath takes the parameter from the route parameter
+	// of the same name, as in /resources/:id.
+	FromPath
+)
+
+var paramSourceNames = map[ParamSource]string{
+	FromForm:   "form",
+	FromHeader: "header",
+	FromCookie: "cookie",
+	FromPath:   "path",
+}
+
+func (s ParamSource) String() string {
+	if name, ok := paramSourceNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("ParamSource(%d)", int(s))
+}
+
+// parseParamSource returns the ParamSource named name, as in "header".
+func parseParamSource(name string) (ParamSource, error) {
+	for s, n := range paramSourceNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown source %q", name)
+}
+
+// setSource records the source passed to a definer, if any.
+func (rp *Params) setSource(name string, source []ParamSource) {
+	if len(source) == 0 || source[0] == FromForm {
+		return
+	}
+	if rp.sources == nil {
+		rp.sources = map[string]ParamSource{}
+	}
+	rp.sources[name] = source[0]
+}
+
+// readsForm reports whether ParseRequest needs the form, because
+// rp is strict or some parameter comes from the form.
+func (rp *Params) readsForm() bool {
+	if rp.strict || rp.fset == nil {
+		return true
+	}
+	form := false
+	rp.fset.VisitAll(func(f *flag.Flag) {
+		if rp.sources[f.Name] == FromForm {
+			form = true
+		}
+	})
+	return form
+}
+
+// values returns the values of the parameter name from source s of r.
+func (s ParamSource) values(r *http.Request, name string) []string {
+	var vals []string
+	switch s {
+	case FromForm:
+		vals = r.Form[name]
+	case FromHeader:
+		vals = r.Header[textproto.CanonicalMIMEHeaderKey(name)]
+	case FromCookie:
+		for _, c := range r.Cookies() {
+			if c.Name == name {
+				vals = append(vals, c.Value)
+			}
+		}
+	case FromPath:
+		params, _ := r.Context().Value(routeParamsKey{}).(routeParams)
+		for _, p := range params {
+			if p.Key == name {
+				vals = append(vals, p.Value)
+			}
+		}
+	}
+	return vals
+}
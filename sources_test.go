@@ -0,0 +1,83 @@
+package siesta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParamSources(t *testing.T) {
+	var (
+		pageSize *int
+		session  *string
+		id       *int
+		query    *string
+		usage    map[string][3]string
+	)
+	s := NewService("/")
+	s.Route(http.MethodGet, "/resources/:id", "", func(w http.ResponseWriter, r *http.Request) {
+		var params Params
+		pageSize = params.Int("X-Page-Size", 50, "Page size", FromHeader)
+		session = params.String("session", "", "Session", FromCookie)
+		id = params.Int("id", 0, "Resource", FromPath)
+		query = params.String("q", "", "Query")
+		params.Required("id")
+		if err := params.ParseRequest(r); err != nil {
+			t.Fatal(err)
+		}
+		usage = params.Usage()
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/resources/7?q=all&id=8&session=forged&X-Page-Size=1", nil)
+	r.Header.Set("X-Page-Size", "20")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	s.ServeHTTP(httptest.NewRecorder(), r)
+
+	if *pageSize != 20 || *session != "abc" || *id != 7 || *query != "all" {
+		t.Errorf("unexpected params %d %q %d %q", *pageSize, *session, *id, *query)
+	}
+	if want, got := "Page size (header)", usage["X-Page-Size"][2]; want != got {
+		t.Errorf("expected usage %q, got %q", want, got)
+	}
+	if want, got := "Resource (path, required)", usage["id"][2]; want != got {
+		t.Errorf("expected usage %q, got %q", want, got)
+	}
+}
+
+func TestParamSourcesLazyForm(t *testing.T) {
+	var params Params
+	pageSize := params.Int("X-Page-Size", 50, "Page size", FromHeader)
+
+	r := httptest.NewRequest(http.MethodGet, "/?X-Page-Size=1", nil)
+	if err := params.ParseRequest(r); err != nil {
+		t.Fatal(err)
+	}
+	if *pageSize != 50 {
+		t.Errorf("expected the default page size, got %d", *pageSize)
+	}
+	if r.Form != nil {
+		t.Error("expected the form to be left unparsed")
+	}
+}
+
+func TestBindSources(t *testing.T) {
+	var dst struct {
+		Session string `param:"session" source:"cookie"`
+		Limit   int    `param:"limit"`
+	}
+	r := httptest.NewRequest(http.MethodGet, "/?limit=5", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	if err := Bind(r, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Session != "abc" || dst.Limit != 5 {
+		t.Errorf("unexpected %+v", dst)
+	}
+
+	var bad struct {
+		Session string `source:"body"`
+	}
+	if err := Bind(r, &bad); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+}
@@ -0,0 +1,287 @@
+package siesta
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrHandlerTimeout is the error of the StatusError set in the Context
+// when a main handler runs past its timeout. It is also returned by the
+// ResponseWriter of the handler after that.
+var ErrHandlerTimeout = errors.New("siesta: handler timed out")
+
+// SetTimeout sets how long the main handler may run. Once the timeout
+// expires, the context of the request is canceled and the handler is
+// abandoned: its writes are discarded, it gets a private copy of the
+// Context, and if it has not written anything yet, the error handler
+// responds with 503 Service Unavailable. The "post" chain runs right away,
+// and it can tell the request timed out by the *StatusError in the Context
+// under ErrorContextKey or the error of the request's context. Zero
+// disables the timeout, which is the default. Routes can override it
+// with the Timeout option.
+func (s *Service) SetTimeout(d time.Duration) {
+	s.timeout = d
+}
+
+// Timeout is a route option that overrides the timeout
+// of the Service. Zero disables the timeout.
+func Timeout(d time.Duration) RouteOption {
+	return func(o *routeOptions) {
+		o.timeout = &d
+	}
+}
+
+// timeoutFor returns the timeout of the route with opts.
+func (s *Service) timeoutFor(opts *routeOptions) time.Duration {
+	if opts != nil && opts.timeout != nil {
+		return *opts.timeout
+	}
+	return s.timeout
+}
+
+// serveWithTimeout runs handler until it returns or ctx, which must
+// be the context of r, expires. It returns true if the handler was
+// abandoned, in which case the handler no longer uses c. release, if
+// not nil, is called once the handler returns, even if abandoned, so
+// that abandoned handlers keep counting towards concurrency limits.
+// They also keep counting as requests in flight until they return.
+func (s *Service) serveWithTimeout(ctx *timeoutContext, handler ContextHandler, c Context, w http.ResponseWriter, r *http.Request, release func()) bool {
+	tw := &timeoutWriter{
+		w: w,
+		h: cloneHeader(w.Header()),
+	}
+
+	hc := newHandlerContext(c)
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	s.holdRequest()
+	go func() {
+		defer func() {
+			s.endRequest()
+			if release != nil {
+				release()
+			}
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+			close(done)
+		}()
+		handler(hc, tw, r, func() {})
+	}()
+
+	timer := time.NewTimer(time.Until(ctx.deadline))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// The client went away. Let the handler finish.
+		<-done
+	case <-timer.C:
+		// Discard the writes of the handler before it
+		// can tell it has been abandoned.
+		tw.mu.Lock()
+		tw.timedOut = true
+		wrote := tw.wroteHeader
+		tw.mu.Unlock()
+		hc.detach()
+		ctx.expire()
+
+		// The handler may still be reading the body, so the
+		// connection is closed rather than drained.
+		w.Header().Set("Connection", "close")
+		err := &StatusError{Status: http.StatusServiceUnavailable, Err: ErrHandlerTimeout}
+		if wrote {
+			c.Set(ErrorContextKey, err)
+		} else {
+			s.serveError(c, w, r, err)
+		}
+		return true
+	}
+
+	select {
+	case p := <-panicChan:
+		panic(p)
+	default:
+	}
+
+	tw.mu.Lock()
+	tw.syncHeader()
+	tw.mu.Unlock()
+	return false
+}
+
+// timeoutContext is a context that serveWithTimeout cancels with
+// context.DeadlineExceeded once it has abandoned the handler.
+type timeoutContext struct {
+	context.Context
+
+	cancel   context.CancelFunc
+	deadline time.Time
+	expired  int32
+}
+
+func newTimeoutContext(parent context.Context, timeout time.Duration) *timeoutContext {
+	ctx, cancel := context.WithCancel(parent)
+	return &timeoutContext{
+		Context:  ctx,
+		cancel:   cancel,
+		deadline: time.Now().Add(timeout),
+	}
+}
+
+func (ctx *timeoutContext) Deadline() (time.Time, bool) {
+	if d, ok := ctx.Context.Deadline(); ok && d.Before(ctx.deadline) {
+		return d, true
+	}
+	return ctx.deadline, true
+}
+
+func (ctx *timeoutContext) Err() error {
+	if atomic.LoadInt32(&ctx.expired) == 1 {
+		return context.DeadlineExceeded
+	}
+	return ctx.Context.Err()
+}
+
+func (ctx *timeoutContext) expire() {
+	atomic.StoreInt32(&ctx.expired, 1)
+	ctx.cancel()
+}
+
+// timeoutWriter is the http.ResponseWriter of a handler with a timeout.
+// The handler gets its own header map so that it can't interfere with
+// the response once abandoned.
+type timeoutWriter struct {
+	w http.ResponseWriter
+	h http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+	tw.syncHeader()
+	tw.wroteHeader = true
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, ErrHandlerTimeout
+	}
+	tw.syncHeader()
+	tw.wroteHeader = true
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		tw.syncHeader()
+		tw.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// syncHeader copies the header of the handler to the response,
+// unless it has been written already. tw.mu must be held.
+func (tw *timeoutWriter) syncHeader() {
+	if tw.wroteHeader {
+		return
+	}
+	dst := tw.w.Header()
+	for k := range dst {
+		if _, ok := tw.h[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range tw.h {
+		dst[k] = append([]string(nil), v...)
+	}
+}
+
+func cloneHeader(h http.Header) http.Header {
+	h2 := make(http.Header, len(h))
+	for k, v := range h {
+		h2[k] = append([]string(nil), v...)
+	}
+	return h2
+}
+
+// handlerContext is the Context of a handler with a timeout. Once the
+// handler is abandoned, detach gives it a private copy of the Context,
+// so that it can't race with the Service and its callers.
+type handlerContext struct {
+	parent Context
+
+	mu       sync.Mutex
+	local    map[string]interface{}
+	detached bool
+}
+
+func newHandlerContext(parent Context) *handlerContext {
+	return &handlerContext{
+		parent: parent,
+		local:  map[string]interface{}{},
+	}
+}
+
+func (c *handlerContext) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.local[key] = value
+	if !c.detached {
+		c.parent.Set(key, value)
+	}
+}
+
+func (c *handlerContext) Get(key string) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.detached {
+		return c.local[key]
+	}
+	value := c.parent.Get(key)
+	c.local[key] = value
+	return value
+}
+
+// detach stops c from using its parent. The copy has all the values of a
+// SiestaContext parent, and otherwise those the handler set or read.
+func (c *handlerContext) detach() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if sc, ok := c.parent.(SiestaContext); ok {
+		for key, value := range sc {
+			c.local[key] = value
+		}
+	}
+	c.detached = true
+}
@@ -0,0 +1,100 @@
+package siesta
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	var (
+		postErr    interface{}
+		postCtxErr error
+		lateWrite  = make(chan error, 1)
+	)
+
+	s := NewService("timeouts")
+	s.SetTimeout(10 * time.Millisecond)
+	s.AddPost(func(c Context, w http.ResponseWriter, r *http.Request) {
+		postErr = c.Get(ErrorContextKey)
+		postCtxErr = r.Context().Err()
+	})
+	s.Route(http.MethodGet, "/slow", "Takes too long", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Header().Set("X-Late", "true")
+		_, err := w.Write([]byte("late"))
+		lateWrite <- err
+	})
+	s.Route(http.MethodGet, "/patient", "Has a longer timeout", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("X-Done", "true")
+	}, Timeout(time.Second))
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeouts/slow", nil))
+
+	if want, got := http.StatusServiceUnavailable, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+	if se, ok := postErr.(*StatusError); !ok || se.Err != ErrHandlerTimeout {
+		t.Errorf("expected a timeout error in the post chain, got %v", postErr)
+	}
+	if want, got := context.DeadlineExceeded, postCtxErr; want != got {
+		t.Errorf("expected context error %v got %v", want, got)
+	}
+	if want, got := ErrHandlerTimeout, <-lateWrite; want != got {
+		t.Errorf("expected late write error %v got %v", want, got)
+	}
+	if got := w.Header().Get("X-Late"); got != "" {
+		t.Errorf("expected no late header, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeouts/patient", nil))
+
+	if want, got := http.StatusOK, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+	if want, got := "true", w.Header().Get("X-Done"); want != got {
+		t.Errorf("expected header %q got %q", want, got)
+	}
+	if postErr != nil {
+		t.Errorf("expected no error, got %v", postErr)
+	}
+}
+
+func TestTimeoutAbandonedContext(t *testing.T) {
+	var (
+		route    interface{}
+		finished = make(chan struct{})
+	)
+
+	s := NewService("abandoned")
+	s.SetTimeout(10 * time.Millisecond)
+	s.AddPre(NewRequestIdentifier().Handle)
+	s.Route(http.MethodGet, "/slow", "Takes too long", func(c Context, w http.ResponseWriter, r *http.Request) {
+		defer close(finished)
+		<-r.Context().Done()
+		for i := 0; i < 100; i++ {
+			c.Set("late", i)
+			time.Sleep(100 * time.Microsecond)
+		}
+		route = c.Get(RouteContextKey)
+	})
+
+	var buf bytes.Buffer
+	h := NewAccessLogger(&buf, CombinedLogFormat).Wrap(s)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abandoned/slow", nil))
+	<-finished
+
+	if want, got := http.StatusServiceUnavailable, w.Code; want != got {
+		t.Errorf("expected status %d got %d", want, got)
+	}
+	if want, got := "/abandoned/slow", route; want != got {
+		t.Errorf("expected the handler to keep its Context values, got %v", got)
+	}
+}
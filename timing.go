@@ -0,0 +1,113 @@
+package siesta
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HandlerTiming is the timeline entry of a handler run by a Service.
+type HandlerTiming struct {
+	// Name is the name of the handler's function,
+	// as in "siesta.(*CORS).Handle".
+	Name string
+	// Chain is "pre", "main" or "post".
+	Chain    string
+	Start    time.Time
+	Duration time.Duration
+	// Quit reports whether the handler called its quit function.
+	Quit bool
+}
+
+// EnableTimings makes s record the timeline of every request. The entries
+// of the handlers that have returned are available as a []HandlerTiming in
+// the Context under TimingsContextKey. They are also sent in a
+// Server-Timing response header, along with the elapsed time of the running
+// handler, when the response header is written.
+func (s *Service) EnableTimings() {
+	s.timings = true
+}
+
+// timeline records the HandlerTimings of a request.
+type timeline struct {
+	mu      sync.Mutex
+	entries []HandlerTiming
+	running *HandlerTiming
+}
+
+// startTimeline starts the timeline of a request if timings are enabled.
+func (s *Service) startTimeline(rw *responseWriter) *timeline {
+	if !s.timings {
+		return nil
+	}
+	tl := &timeline{}
+	rw.beforeHeader(func(int) {
+		if v := tl.serverTiming(); v != "" {
+			rw.Header().Set("Server-Timing", v)
+		}
+	})
+	return tl
+}
+
+// time returns h wrapped to record its timing in tl.
+func (tl *timeline) time(chain, name string, h ContextHandler) ContextHandler {
+	return func(c Context, w http.ResponseWriter, r *http.Request, quit func()) {
+		entry := &HandlerTiming{
+			Name:  name,
+			Chain: chain,
+			Start: time.Now(),
+		}
+		tl.mu.Lock()
+		tl.running = entry
+		tl.mu.Unlock()
+
+		defer func() {
+			tl.mu.Lock()
+			entry.Duration = time.Since(entry.Start)
+			tl.entries = append(tl.entries, *entry)
+			if tl.running == entry {
+				tl.running = nil
+			}
+			entries := append([]HandlerTiming(nil), tl.entries...)
+			tl.mu.Unlock()
+
+			c.Set(TimingsContextKey, entries)
+		}()
+
+		h(c, w, r, func() {
+			tl.mu.Lock()
+			entry.Quit = true
+			tl.mu.Unlock()
+			quit()
+		})
+	}
+}
+
+// serverTiming returns the value of the Server-Timing header. Metric
+// names are the chains of the handlers followed by their position.
+func (tl *timeline) serverTiming() string {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	entries := tl.entries
+	if tl.running != nil {
+		running := *tl.running
+		running.Duration = time.Since(running.Start)
+		entries = append(entries[:len(entries):len(entries)], running)
+	}
+
+	var parts []string
+	positions := map[string]int{}
+	for _, e := range entries {
+		metric := e.Chain
+		if e.Chain != "main" {
+			metric += strconv.Itoa(positions[e.Chain])
+			positions[e.Chain]++
+		}
+		dur := strconv.FormatFloat(float64(e.Duration)/float64(time.Millisecond), 'f', 3, 64)
+		parts = append(parts, metric+";dur="+dur+";desc="+strconv.Quote(e.Name))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,55 @@
+package siesta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestTimings(t *testing.T) {
+	s := NewService("timings")
+	s.EnableTimings()
+	s.AddPre(NewRequestIdentifier().Handle)
+	s.AddPre(func(c Context, w http.ResponseWriter, r *http.Request, quit func()) {
+		if r.URL.Query().Get("quit") != "" {
+			w.WriteHeader(http.StatusForbidden)
+			quit()
+		}
+	})
+
+	var timings []HandlerTiming
+	s.AddPost(func(c Context, w http.ResponseWriter, r *http.Request) {
+		timings, _ = c.Get(TimingsContextKey).([]HandlerTiming)
+	})
+	s.Route(http.MethodGet, "/items", "Lists items", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timings/items", nil))
+
+	if len(timings) != 3 {
+		t.Fatalf("expected 3 timings got %d", len(timings))
+	}
+	if want, got := "siesta.(*RequestIdentifier).Handle", timings[0].Name; want != got {
+		t.Errorf("expected name %q got %q", want, got)
+	}
+	if want, got := "main", timings[2].Chain; want != got {
+		t.Errorf("expected chain %q got %q", want, got)
+	}
+	re := regexp.MustCompile(`^pre0;dur=\d+\.\d{3};desc="siesta\.\(\*RequestIdentifier\)\.Handle", pre1;dur=[\d.]+;desc="siesta\.TestTimings\.func1", main;dur=[\d.]+;desc="siesta\.TestTimings\.func3"$`)
+	if h := w.Header().Get("Server-Timing"); !re.MatchString(h) {
+		t.Errorf("unexpected Server-Timing %q", h)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timings/items?quit=1", nil))
+
+	if len(timings) != 2 || !timings[1].Quit {
+		t.Errorf("expected the second handler to quit, got %+v", timings)
+	}
+	if h := w.Header().Get("Server-Timing"); !regexp.MustCompile(`^pre0;.*, pre1;`).MatchString(h) {
+		t.Errorf("unexpected Server-Timing %q", h)
+	}
+}
@@ -0,0 +1,300 @@
+package siesta
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceContextKey holds the *requestTrace of a request.
+const traceContextKey = nullByteStr + "trace"
+
+// Span is a timed operation within a trace. Every request traced by a
+// Service has a span named after its verb and route pattern, with a child
+// span for each handler that ran.
+type Span struct {
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Name       string            `json:"name"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// SpanExporter sends finished spans somewhere. Implementations
+// must be safe for concurrent use.
+type SpanExporter interface {
+	// ExportSpans exports the spans of a request.
+	ExportSpans(spans []*Span) error
+}
+
+// Tracer traces the requests served by a Service. It continues the
+// traces of requests carrying a W3C traceparent header and starts new
+// ones for the rest. Requests whose traceparent is not sampled are
+// propagated but not exported.
+type Tracer struct {
+	exporter SpanExporter
+}
+
+// NewTracer returns a Tracer exporting spans to exporter.
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// SetTracer sets the Tracer of s. The trace ID of every request is
+// available in the Context under TraceIDContextKey, and the traceparent
+// and tracestate headers of the request's span are set on the response.
+// A nil Tracer disables tracing, which is the default.
+func (s *Service) SetTracer(t *Tracer) {
+	s.tracer = t
+}
+
+// SetTraceHeaders sets the traceparent and tracestate headers in h to
+// continue the trace of the request with Context c, usually in an
+// outgoing request. It does nothing if the request is not traced.
+func SetTraceHeaders(c Context, h http.Header) {
+	tr, ok := c.Get(traceContextKey).(*requestTrace)
+	if !ok {
+		return
+	}
+	tr.mu.Lock()
+	spanID := tr.current.SpanID
+	tr.mu.Unlock()
+	tr.setHeaders(h, spanID)
+}
+
+// requestTrace holds the spans of a request.
+type requestTrace struct {
+	tracer  *Tracer
+	sampled bool
+	state   string
+
+	mu      sync.Mutex
+	root    *Span
+	current *Span
+	spans   []*Span
+}
+
+// startTrace starts the span of r. The span is ended by requestTrace.end.
+func (s *Service) startTrace(c Context, w http.ResponseWriter, r *http.Request) *requestTrace {
+	if s.tracer == nil {
+		return nil
+	}
+
+	root := &Span{
+		Name:  r.Method,
+		Start: time.Now(),
+		Attributes: map[string]string{
+			"http.method": r.Method,
+			"http.target": r.URL.RequestURI(),
+		},
+	}
+	tr := &requestTrace{
+		tracer:  s.tracer,
+		sampled: true,
+		root:    root,
+		current: root,
+	}
+
+	traceID, parentID, flags, ok := parseTraceparent(r.Header.Get("traceparent"))
+	if ok {
+		root.TraceID = traceID
+		root.ParentID = parentID
+		tr.sampled = flags&1 == 1
+		tr.state = strings.TrimSpace(strings.Join(r.Header["Tracestate"], ","))
+	} else {
+		root.TraceID = randomHex(16)
+	}
+	root.SpanID = randomHex(8)
+
+	c.Set(traceContextKey, tr)
+	c.Set(TraceIDContextKey, root.TraceID)
+	tr.setHeaders(w.Header(), root.SpanID)
+	return tr
+}
+
+// setHeaders sets the traceparent and tracestate headers for spanID.
+func (tr *requestTrace) setHeaders(h http.Header, spanID string) {
+	flags := "00"
+	if tr.sampled {
+		flags = "01"
+	}
+	h.Set("traceparent", "00-"+tr.root.TraceID+"-"+spanID+"-"+flags)
+	if tr.state != "" {
+		h.Set("tracestate", tr.state)
+	}
+}
+
+// trace returns h wrapped to record a span named name, as a child of the
+// request's span. The chain attribute is the chain h belongs to.
+func (tr *requestTrace) trace(chain, name string, h ContextHandler) ContextHandler {
+	return func(c Context, w http.ResponseWriter, r *http.Request, quit func()) {
+		span := &Span{
+			TraceID:    tr.root.TraceID,
+			SpanID:     randomHex(8),
+			ParentID:   tr.root.SpanID,
+			Name:       name,
+			Start:      time.Now(),
+			Attributes: map[string]string{"siesta.chain": chain},
+		}
+		tr.mu.Lock()
+		tr.current = span
+		tr.mu.Unlock()
+
+		quitCalled := false
+		defer func() {
+			span.End = time.Now()
+			if quitCalled {
+				span.Attributes["siesta.quit"] = "true"
+			}
+			tr.mu.Lock()
+			tr.spans = append(tr.spans, span)
+			if tr.current == span {
+				tr.current = tr.root
+			}
+			tr.mu.Unlock()
+		}()
+
+		h(c, w, r, func() {
+			quitCalled = true
+			quit()
+		})
+	}
+}
+
+// end ends the span of the request and exports the trace. Spans of
+// handlers that are still running, such as abandoned ones, are dropped.
+func (tr *requestTrace) end(c Context, status int, panicValue interface{}) {
+	root := tr.root
+	root.End = time.Now()
+	if pattern, _ := c.Get(RouteContextKey).(string); pattern != "" {
+		root.Name = root.Attributes["http.method"] + " " + pattern
+		root.Attributes["http.route"] = pattern
+	}
+	root.Attributes["http.status_code"] = strconv.Itoa(status)
+	if panicValue != nil {
+		root.Attributes["error"] = "panic"
+	}
+
+	if !tr.sampled {
+		return
+	}
+	tr.mu.Lock()
+	spans := append(tr.spans, root)
+	tr.spans = nil
+	tr.mu.Unlock()
+
+	tr.tracer.exporter.ExportSpans(spans)
+}
+
+// parseTraceparent parses a traceparent header.
+func parseTraceparent(header string) (traceID, parentID string, flags byte, ok bool) {
+	header = strings.TrimSpace(header)
+	if len(header) < 55 {
+		return "", "", 0, false
+	}
+	parts := strings.SplitN(header, "-", 5)
+	if len(parts) < 4 {
+		return "", "", 0, false
+	}
+	version, traceID, parentID, flagsHex := parts[0], parts[1], parts[2], parts[3]
+
+	// Later versions may append fields, but version 00 may not.
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) > 4) {
+		return "", "", 0, false
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) ||
+		!isLowerHex(parentID, 16) || parentID == strings.Repeat("0", 16) ||
+		!isLowerHex(flagsHex, 2) {
+		return "", "", 0, false
+	}
+
+	b, _ := hex.DecodeString(flagsHex)
+	return traceID, parentID, b[0], true
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes encoded in hexadecimal.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// IDs must not be all zeros.
+		b[n-1] = 1
+	}
+	return hex.EncodeToString(b)
+}
+
+// MemorySpanExporter keeps exported spans in memory.
+// It is mostly useful in tests.
+type MemorySpanExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// ExportSpans satisfies the SpanExporter interface.
+func (e *MemorySpanExporter) ExportSpans(spans []*Span) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	e.mu.Unlock()
+	return nil
+}
+
+// Spans returns the spans exported so far.
+func (e *MemorySpanExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset discards the spans exported so far.
+func (e *MemorySpanExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
+
+// JSONSpanExporter writes every span as a JSON object on its own line.
+type JSONSpanExporter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewJSONSpanExporter returns a JSONSpanExporter writing to out. Writes
+// are serialized, so out doesn't need to be safe for concurrent use.
+func NewJSONSpanExporter(out io.Writer) *JSONSpanExporter {
+	return &JSONSpanExporter{out: out}
+}
+
+// ExportSpans satisfies the SpanExporter interface.
+func (e *JSONSpanExporter) ExportSpans(spans []*Span) error {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	for _, span := range spans {
+		if err := enc.Encode(span); err != nil {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := io.WriteString(e.out, buf.String())
+	return err
+}
@@ -0,0 +1,126 @@
+package siesta
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := &MemorySpanExporter{}
+
+	s := NewService("tracing")
+	s.SetTracer(NewTracer(exporter))
+	s.AddPre(NewRequestIdentifier().Handle)
+
+	var traceID interface{}
+	outgoing := http.Header{}
+	s.Route(http.MethodGet, "/items/:id", "Gets an item", func(c Context, w http.ResponseWriter, r *http.Request) {
+		traceID = c.Get(TraceIDContextKey)
+		SetTraceHeaders(c, outgoing)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/tracing/items/1", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set("tracestate", "vendor=value")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if want := "4bf92f3577b34da6a3ce929d0e0e4736"; traceID != want {
+		t.Errorf("expected trace ID %q got %v", want, traceID)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans got %d", len(spans))
+	}
+	pre, main, root := spans[0], spans[1], spans[2]
+
+	if want := "GET /tracing/items/:id"; root.Name != want {
+		t.Errorf("expected root span %q got %q", want, root.Name)
+	}
+	if want := "00f067aa0ba902b7"; root.ParentID != want {
+		t.Errorf("expected parent %q got %q", want, root.ParentID)
+	}
+	if want := "200"; root.Attributes["http.status_code"] != want {
+		t.Errorf("expected status %q got %q", want, root.Attributes["http.status_code"])
+	}
+	if want := "siesta.(*RequestIdentifier).Handle"; pre.Name != want {
+		t.Errorf("expected pre span %q got %q", want, pre.Name)
+	}
+	if want := "main"; main.Attributes["siesta.chain"] != want {
+		t.Errorf("expected chain %q got %q", want, main.Attributes["siesta.chain"])
+	}
+	for _, span := range []*Span{pre, main} {
+		if span.ParentID != root.SpanID || span.TraceID != root.TraceID {
+			t.Errorf("span %q is not a child of the request span", span.Name)
+		}
+	}
+
+	if want := "00-" + root.TraceID + "-" + root.SpanID + "-01"; w.Header().Get("traceparent") != want {
+		t.Errorf("expected traceparent %q got %q", want, w.Header().Get("traceparent"))
+	}
+	if want := "00-" + root.TraceID + "-" + main.SpanID + "-01"; outgoing.Get("traceparent") != want {
+		t.Errorf("expected outgoing traceparent %q got %q", want, outgoing.Get("traceparent"))
+	}
+	if want := "vendor=value"; outgoing.Get("tracestate") != want {
+		t.Errorf("expected tracestate %q got %q", want, outgoing.Get("tracestate"))
+	}
+}
+
+func TestTracingNotSampled(t *testing.T) {
+	exporter := &MemorySpanExporter{}
+
+	s := NewService("tracing-unsampled")
+	s.SetTracer(NewTracer(exporter))
+
+	r := httptest.NewRequest(http.MethodGet, "/tracing-unsampled/", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if n := len(exporter.Spans()); n != 0 {
+		t.Errorf("expected no spans got %d", n)
+	}
+	if h := w.Header().Get("traceparent"); !strings.HasPrefix(h, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || !strings.HasSuffix(h, "-00") {
+		t.Errorf("unexpected traceparent %q", h)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	for header, valid := range map[string]bool{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":      true,
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-beef": true,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-beef": false,
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":      false,
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01":      false,
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01":      false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01":      false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7":         false,
+	} {
+		if _, _, _, ok := parseTraceparent(header); ok != valid {
+			t.Errorf("%q: expected valid %v got %v", header, valid, ok)
+		}
+	}
+}
+
+func TestJSONSpanExporter(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewJSONSpanExporter(&buf)
+	e.ExportSpans([]*Span{{TraceID: "a", SpanID: "b", Name: "one"}, {TraceID: "a", SpanID: "c", Name: "two"}})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines got %d", len(lines))
+	}
+	var span Span
+	if err := json.Unmarshal([]byte(lines[1]), &span); err != nil {
+		t.Fatal(err)
+	}
+	if span.Name != "two" || span.TraceID != "a" {
+		t.Errorf("unexpected span %+v", span)
+	}
+}
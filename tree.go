@@ -69,6 +69,7 @@ type node struct {
 	children  []*node
 	handle    ContextHandler
 	usage     string
+	pattern   string
 	priority  uint32
 }
 
@@ -95,6 +96,7 @@ func (n *node) incrementChildPrio(i int) int {
 // addRoute adds a node with the given handle to the path.
 // Not concurrency-safe!
 func (n *node) addRoute(path string, usage string, handle ContextHandler) {
+	fullPath := path
 	n.priority++
 	numParams := countParams(path)
 
@@ -123,6 +125,7 @@ func (n *node) addRoute(path string, usage string, handle ContextHandler) {
 					children:  n.children,
 					handle:    n.handle,
 					usage:     n.usage,
+					pattern:   n.pattern,
 					priority:  n.priority - 1,
 				}
 
@@ -138,6 +141,7 @@ func (n *node) addRoute(path string, usage string, handle ContextHandler) {
 				n.path = path[:i]
 				n.handle = nil
 				n.usage = ""
+				n.pattern = ""
 				n.wildChild = false
 			}
 
@@ -194,7 +198,7 @@ func (n *node) addRoute(path string, usage string, handle ContextHandler) {
 					n.incrementChildPrio(len(n.indices) - 1)
 					n = child
 				}
-				n.insertChild(numParams, path, usage, handle)
+				n.insertChild(numParams, path, fullPath, usage, handle)
 				return
 
 			} else if i == len(path) { // Make node a (in-path) leaf
@@ -203,15 +207,16 @@ func (n *node) addRoute(path string, usage string, handle ContextHandler) {
 				}
 				n.handle = handle
 				n.usage = usage
+				n.pattern = fullPath
 			}
 			return
 		}
 	} else { // Empty tree
-		n.insertChild(numParams, path, usage, handle)
+		n.insertChild(numParams, path, fullPath, usage, handle)
 	}
 }
 
-func (n *node) insertChild(numParams uint8, path string, usage string, handle ContextHandler) {
+func (n *node) insertChild(numParams uint8, path, fullPath string, usage string, handle ContextHandler) {
 	var offset int // already handled bytes of the path
 
 	// find prefix until first wildcard (beginning with ':'' or '*'')
@@ -310,6 +315,7 @@ func (n *node) insertChild(numParams uint8, path string, usage string, handle Co
 				maxParams: 1,
 				handle:    handle,
 				usage:     usage,
+				pattern:   fullPath,
 				priority:  1,
 			}
 			n.children = []*node{child}
@@ -322,14 +328,16 @@ func (n *node) insertChild(numParams uint8, path string, usage string, handle Co
 	n.path = path[offset:]
 	n.handle = handle
 	n.usage = usage
+	n.pattern = fullPath
 }
 
-// Returns the handle registered with the given path (key). The values of
+// Returns the handle registered with the given path (key), along with its
+// usage and the route pattern it was registered with. The values of
 // wildcards are saved to a map.
 // If no handle can be found, a TSR (trailing slash redirect) recommendation is
 // made if a handle exists with an extra (without the) trailing slash for the
 // given path.
-func (n *node) getValue(path string) (handle ContextHandler, usage string, p routeParams, tsr bool) {
+func (n *node) getValue(path string) (handle ContextHandler, usage, pattern string, p routeParams, tsr bool) {
 walk: // Outer loop for walking the tree
 	for {
 		if len(path) > len(n.path) {
@@ -388,7 +396,7 @@ walk: // Outer loop for walking the tree
 						return
 					}
 
-					if handle, usage = n.handle, n.usage; handle != nil {
+					if handle, usage, pattern = n.handle, n.usage, n.pattern; handle != nil {
 						return
 					} else if len(n.children) == 1 {
 						// No handle found. Check if a handle for this path + a
@@ -412,6 +420,7 @@ walk: // Outer loop for walking the tree
 
 					handle = n.handle
 					usage = n.usage
+					pattern = n.pattern
 					return
 
 				default:
@@ -421,7 +430,7 @@ walk: // Outer loop for walking the tree
 		} else if path == n.path {
 			// We should have reached the node containing the handle.
 			// Check if this node has a handle registered.
-			if handle, usage = n.handle, n.usage; handle != nil {
+			if handle, usage, pattern = n.handle, n.usage, n.pattern; handle != nil {
 				return
 			}
 
@@ -0,0 +1,308 @@
+package siesta
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// cleanupContextKey holds the *cleanups of a request.
+const cleanupContextKey = nullByteStr + "cleanup"
+
+// maxUploadValueSize is the maximum size of the non-file parts of
+// a multipart body read by Uploads.
+const maxUploadValueSize = 1 << 20
+
+// defaultMaxUploadValuesSize is the default maximum total size of the
+// non-file parts of a multipart body read by Uploads.
+const defaultMaxUploadValuesSize = 10 << 20
+
+// UploadedFile is a file received by Uploads.
+type UploadedFile struct {
+	// Filename is the name of the file given by the client.
+	Filename string
+	// ContentType is the media type given by the client.
+	ContentType string
+	// Size is the size of the file in bytes.
+	Size int64
+	// SHA256 is the SHA-256 checksum of the file, in hexadecimal.
+	SHA256 string
+	// Path is the path of the temporary file holding the contents,
+	// or empty if they were written to an io.Writer.
+	Path string
+}
+
+// Open opens the temporary file holding the contents of f.
+func (f *UploadedFile) Open() (*os.File, error) {
+	return os.Open(f.Path)
+}
+
+// Uploads receives the files of a multipart request. Like Params, it is
+// declared with a definer per file field, and then parsed. Files are
+// streamed as they arrive, to temporary files or to an io.Writer,
+// without holding them in memory.
+//
+// The other parts of the body are added to the form of the request, so
+// they can be parsed by a Params afterwards. Parts of file fields that
+// are not declared are discarded.
+//
+// The body must be left unread by the form policy of the route, so
+// routes with the ParseMultipart policy can't use Uploads, and neither
+// can those with the ParseLazily policy once the form is parsed.
+type Uploads struct {
+	dir           string
+	fields        map[string]*uploadField
+	maxValuesSize int64
+
+	mu    sync.Mutex
+	paths []string
+}
+
+type uploadField struct {
+	file     *UploadedFile
+	dst      io.Writer
+	maxSize  int64
+	types    []string
+	usage    string
+	received bool
+}
+
+// SetDir sets the directory of the temporary files.
+// It defaults to os.TempDir().
+func (u *Uploads) SetDir(dir string) {
+	u.dir = dir
+}
+
+// SetMaxValuesSize sets the maximum total size of the parts of the body
+// that are not files, which are kept in memory. It defaults to 10 MiB.
+func (u *Uploads) SetMaxValuesSize(n int64) {
+	u.maxValuesSize = n
+}
+
+// File declares a file field whose contents are stored in a temporary file.
+// Files larger than maxSize bytes are rejected, as are those without one of
+// the given media types, if any. A type may end with "/*" to match all of
+// its subtypes. The return value is filled in by Parse.
+func (u *Uploads) File(name string, maxSize int64, usage string, types ...string) *UploadedFile {
+	return u.define(name, nil, maxSize, usage, types)
+}
+
+// FileTo declares a file field whose contents are written to dst,
+// which gets the start of files that are eventually rejected for
+// being too large. See File.
+func (u *Uploads) FileTo(name string, dst io.Writer, maxSize int64, usage string, types ...string) *UploadedFile {
+	return u.define(name, dst, maxSize, usage, types)
+}
+
+func (u *Uploads) define(name string, dst io.Writer, maxSize int64, usage string, types []string) *UploadedFile {
+	if u.fields == nil {
+		u.fields = map[string]*uploadField{}
+	}
+	f := &uploadField{
+		file:    &UploadedFile{},
+		dst:     dst,
+		maxSize: maxSize,
+		types:   types,
+		usage:   usage,
+	}
+	u.fields[name] = f
+	return f.file
+}
+
+// IsSet reports whether a file was received for the field.
+func (u *Uploads) IsSet(name string) bool {
+	f := u.fields[name]
+	return f != nil && f.received
+}
+
+// Usage returns a map keyed on file field names, like Params.Usage.
+func (u *Uploads) Usage() map[string][3]string {
+	docs := make(map[string][3]string)
+	for name, f := range u.fields {
+		notes := []string{fmt.Sprintf("max size %d", f.maxSize)}
+		if len(f.types) > 0 {
+			notes = append(notes, "one of "+strings.Join(f.types, "|"))
+		}
+		docs[name] = [...]string{name, "file", f.usage + " (" + strings.Join(notes, ", ") + ")"}
+	}
+	return docs
+}
+
+// Parse reads the multipart body of r. Problems with the files, and
+// values over the size limits, are returned as ParamErrors. The
+// temporary files are removed once the Service is done with the request
+// with Context c; Cleanup removes them earlier, or outside of a Service.
+func (u *Uploads) Parse(c Context, r *http.Request) error {
+	onRequestEnd(c, u.Cleanup)
+
+	if r.MultipartForm != nil {
+		return errors.New("siesta: Uploads can't parse a multipart body already parsed as a form")
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	if r.Form == nil {
+		r.Form = url.Values{}
+	}
+	if r.PostForm == nil {
+		r.PostForm = url.Values{}
+	}
+	budget := u.maxValuesSize
+	if budget <= 0 {
+		budget = defaultMaxUploadValuesSize
+	}
+	remaining := budget
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if part.FileName() == "" {
+			value, err := ioutil.ReadAll(io.LimitReader(part, maxUploadValueSize+1))
+			if err != nil {
+				return err
+			}
+			if len(value) > maxUploadValueSize {
+				return ParamErrors{{Name: name, Reason: fmt.Sprintf("must be at most %d bytes", maxUploadValueSize)}}
+			}
+			if remaining -= int64(len(value)); remaining < 0 {
+				return bodyError(fmt.Sprintf("must have at most %d bytes of values", budget))
+			}
+			r.Form.Add(name, string(value))
+			r.PostForm.Add(name, string(value))
+			continue
+		}
+
+		f := u.fields[name]
+		if f == nil {
+			continue
+		}
+		if err := u.receive(name, f, part.FileName(), part.Header.Get("Content-Type"), part); err != nil {
+			return err
+		}
+	}
+}
+
+// receive streams the file of field f from src.
+func (u *Uploads) receive(name string, f *uploadField, filename, contentType string, src io.Reader) error {
+	if f.received {
+		return ParamErrors{{Name: name, Value: filename, Type: "file", Reason: "must be a single file"}}
+	}
+	if len(f.types) > 0 && !matchMediaType(f.types, contentType) {
+		return ParamErrors{{Name: name, Value: filename, Type: "file", Reason: "must be one of " + strings.Join(f.types, ", ")}}
+	}
+
+	dst := f.dst
+	path := ""
+	if dst == nil {
+		tmp, err := ioutil.TempFile(u.dir, "siesta-upload-*")
+		if err != nil {
+			return err
+		}
+		defer tmp.Close()
+		path = tmp.Name()
+		u.mu.Lock()
+		u.paths = append(u.paths, path)
+		u.mu.Unlock()
+		dst = tmp
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(dst, h), io.LimitReader(src, f.maxSize+1))
+	if err != nil {
+		return err
+	}
+	if n > f.maxSize {
+		return ParamErrors{{Name: name, Value: filename, Type: "file", Reason: fmt.Sprintf("must be at most %d bytes", f.maxSize)}}
+	}
+
+	f.received = true
+	*f.file = UploadedFile{
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        n,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		Path:        path,
+	}
+	return nil
+}
+
+// Cleanup removes the temporary files.
+func (u *Uploads) Cleanup() {
+	u.mu.Lock()
+	paths := u.paths
+	u.paths = nil
+	u.mu.Unlock()
+
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// matchMediaType reports whether contentType has one of types.
+func matchMediaType(types []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range types {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanups are functions run once a Service is done with a request.
+type cleanups struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// onRequestEnd arranges for f to run once the Service
+// is done with the request with Context c.
+func onRequestEnd(c Context, f func()) {
+	cl, ok := c.Get(cleanupContextKey).(*cleanups)
+	if !ok {
+		cl = &cleanups{}
+		c.Set(cleanupContextKey, cl)
+	}
+	cl.mu.Lock()
+	cl.fns = append(cl.fns, f)
+	cl.mu.Unlock()
+}
+
+// runCleanups runs the functions added by onRequestEnd.
+func runCleanups(c Context) {
+	cl, ok := c.Get(cleanupContextKey).(*cleanups)
+	if !ok {
+		return
+	}
+	cl.mu.Lock()
+	fns := cl.fns
+	cl.fns = nil
+	cl.mu.Unlock()
+
+	for _, f := range fns {
+		f()
+	}
+}
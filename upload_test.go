@@ -0,0 +1,180 @@
+package siesta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"testing"
+)
+
+type uploadPart struct {
+	name, filename, contentType, body string
+}
+
+func newUploadRequest(t *testing.T, parts ...uploadPart) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, p := range parts {
+		h := textproto.MIMEHeader{}
+		if p.filename != "" {
+			h.Set("Content-Disposition", `form-data; name="`+p.name+`"; filename="`+p.filename+`"`)
+			h.Set("Content-Type", p.contentType)
+		} else {
+			h.Set("Content-Disposition", `form-data; name="`+p.name+`"`)
+		}
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(p.body))
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestUploads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "siesta-upload-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		avatar   *UploadedFile
+		path     string
+		contents []byte
+		title    string
+		log      bytes.Buffer
+		logFile  *UploadedFile
+	)
+	s := NewService("/")
+	s.Route(http.MethodPost, "/upload", "", func(c Context, w http.ResponseWriter, r *http.Request) {
+		var uploads Uploads
+		uploads.SetDir(dir)
+		avatar = uploads.File("avatar", 100, "Avatar", "image/*")
+		logFile = uploads.FileTo("log", &log, 100, "Log")
+		if err := uploads.Parse(c, r); err != nil {
+			t.Fatal(err)
+		}
+
+		var params Params
+		t := params.String("title", "", "Title")
+		if err := params.Parse(r.Form); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		title = *t
+		path = avatar.Path
+		contents, _ = ioutil.ReadFile(path)
+	})
+
+	r := newUploadRequest(t,
+		uploadPart{name: "title", body: "Me"},
+		uploadPart{name: "avatar", filename: "me.png", contentType: "image/png", body: "PNG data"},
+		uploadPart{name: "log", filename: "app.log", contentType: "text/plain", body: "log data"},
+		uploadPart{name: "other", filename: "other.txt", contentType: "text/plain", body: "ignored"},
+	)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if title != "Me" {
+		t.Errorf("expected title %q, got %q", "Me", title)
+	}
+	if string(contents) != "PNG data" {
+		t.Errorf("expected avatar contents %q, got %q", "PNG data", contents)
+	}
+	sum := sha256.Sum256([]byte("PNG data"))
+	if avatar.Filename != "me.png" || avatar.ContentType != "image/png" || avatar.Size != 8 || avatar.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected avatar %+v", avatar)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", path, err)
+	}
+
+	if log.String() != "log data" {
+		t.Errorf("expected log %q, got %q", "log data", log.String())
+	}
+	if logFile.Path != "" || logFile.Size != 8 {
+		t.Errorf("unexpected log file %+v", logFile)
+	}
+}
+
+func TestUploadsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		part uploadPart
+		err  string
+	}{
+		{uploadPart{name: "avatar", filename: "me.gif", contentType: "image/gif", body: "GIF"},
+			"bad param 'avatar': must be one of image/png, image/jpeg"},
+		{uploadPart{name: "avatar", filename: "me.png", contentType: "image/png", body: "too much data"},
+			"bad param 'avatar': must be at most 8 bytes"},
+	} {
+		var uploads Uploads
+		uploads.File("avatar", 8, "Avatar", "image/png", "image/jpeg")
+		c := NewSiestaContext()
+		err := uploads.Parse(c, newUploadRequest(t, tc.part))
+		if _, ok := err.(ParamErrors); !ok || err.Error() != tc.err {
+			t.Errorf("expected %q, got %v", tc.err, err)
+		}
+		if uploads.IsSet("avatar") {
+			t.Errorf("expected avatar to be unset")
+		}
+		runCleanups(c)
+	}
+}
+
+func TestUploadsValuesSize(t *testing.T) {
+	var parts []uploadPart
+	for i := 0; i < 5; i++ {
+		parts = append(parts, uploadPart{name: "note", body: "0123456789"})
+	}
+
+	var uploads Uploads
+	uploads.SetMaxValuesSize(45)
+	r := newUploadRequest(t, parts...)
+	err := uploads.Parse(NewSiestaContext(), r)
+	if want := "bad param 'body': must have at most 45 bytes of values"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+	if _, ok := err.(ParamErrors); !ok {
+		t.Errorf("expected ParamErrors, got %T", err)
+	}
+	if got := len(r.PostForm["note"]); got != 4 {
+		t.Errorf("expected 4 values to be read, got %d", got)
+	}
+
+	uploads.SetMaxValuesSize(50)
+	if err := uploads.Parse(NewSiestaContext(), newUploadRequest(t, parts...)); err != nil {
+		t.Errorf("expected the values to fit, got %v", err)
+	}
+}
+
+func TestUploadsParsedForm(t *testing.T) {
+	var err error
+	s := NewService("/")
+	s.SetFormPolicy(ParseMultipart)
+	s.Route(http.MethodPost, "/upload", "", func(c Context, w http.ResponseWriter, r *http.Request) {
+		var uploads Uploads
+		uploads.File("avatar", 100, "Avatar")
+		err = uploads.Parse(c, r)
+	})
+	s.ServeHTTP(httptest.NewRecorder(), newUploadRequest(t,
+		uploadPart{name: "avatar", filename: "me.png", contentType: "image/png", body: "PNG data"},
+	))
+
+	if want := "siesta: Uploads can't parse a multipart body already parsed as a form"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}